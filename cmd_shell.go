@@ -3,6 +3,7 @@ package easyshell
 import (
 	"context"
 	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/charset"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"os/exec"
 	"regexp"
@@ -21,7 +22,7 @@ func (c *CmdShellConfig) EnsureInit() {
 	switch runtime.GOOS {
 	case "windows":
 		if c.Decoder == nil {
-			c.Decoder = simplifiedchinese.GB18030.NewDecoder().Bytes
+			c.Decoder = charset.Fixed(simplifiedchinese.GB18030)
 		}
 
 		if len(c.PromptRegex) == 0 {
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"github.com/3th1nk/easyshell/pkg/charset"
 	"github.com/3th1nk/easyshell/pkg/filter"
+	"github.com/3th1nk/easyshell/pkg/style"
 	"io"
 	"regexp"
 	"time"
@@ -11,11 +13,16 @@ type Config struct {
 	// 输出 io.Reader 中读取的原始数据，用于上层调试
 	RawOut io.Writer
 
-	// 从 io.Reader 中读取到数据后，用来过滤特殊字符的自定义函数，在 Decoder 前执行
-	Filter filter.IFilter
+	// 用来创建过滤特殊字符的过滤器，在 Decoder 后执行，处理的是解码后的 UTF8 内容（如退格按字符宽度删除）；过滤器是有状态的，每个输出流使用单独的过滤器
+	Filter filter.Factory
 
-	// 从 io.Reader 中读取到数据后，用来解码的自定义函数
-	Decoder func(b []byte) ([]byte, error)
+	// 从 io.Reader 中读取到数据后，用来创建解码器（转换为 UTF8），如 charset.Fixed(simplifiedchinese.GB18030)
+	//	解码器是有状态的，被拆分到多次读取中的多字节字符不会产生乱码；默认每个会话使用一个 charset.Auto()，首次确定编码后固定使用该编码
+	Decoder charset.Factory
+
+	// 带样式的输出的渲染器（如 style.HTML()），不为 nil 时解析输出中的颜色、字体，OnOut 收到的是渲染后的内容，提示符、拦截器仍然匹配纯文本
+	//	使用默认过滤器时自动保留颜色控制序列；使用自定义过滤器时，过滤器需要保留 SGR 控制序列（如 filter.ANSIKeepSGR）
+	Renderer style.Renderer
 
 	// 命令行提示符的匹配规则
 	PromptRegex []*regexp.Regexp
@@ -32,6 +39,10 @@ type Config struct {
 	// 调用 ReadToEndLine 时的确认间隔
 	ReadConfirmWait time.Duration
 
+	// 空闲超时时间，大于 0 时，读取过程中超过该时间没有读取到任何输出，返回 Op 为 idle 的错误，默认不检测
+	//	与 ReadXXX 函数的 timeout（总超时时间）同时生效，适用于长时间执行、但持续有输出的命令（如 ping、文件复制）
+	IdleTimeout time.Duration
+
 	// 调用 ReadXXX 函数前的自定义回调函数
 	BeforeRead func() error
 
@@ -41,4 +52,15 @@ type Config struct {
 	// 延迟触发 OnOut 的缓冲区大小
 	//   如果需要在超过指定间隔或输出内容超过指定长度后再触发 OnOut、而不是实时触发 OnOut，可以指定 LazyOutInterval 和 LazyOutSize
 	LazyOutSize int
+
+	// 会话初始化命令，在连接建立后以及每次自动重连成功后依次执行，如 terminal length 0、enable 等
+	InitCommands []string
+	// 执行每个会话初始化命令的超时时间，默认值 30 秒
+	InitTimeout time.Duration
+
+	// 自动重连策略，默认不自动重连，仅对支持重连的 Shell（SshShell、TelnetShell）有效
+	Reconnect ReconnectPolicy
+
+	// 调用 Exec 时包装命令以获取退出码的函数，默认值 PosixExitCode
+	ExitCodeWrapper ExitCodeWrapper
 }
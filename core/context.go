@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"time"
+)
+
+// ReadToEndLineContext 与 ReadToEndLine 相同，使用 ctx 控制超时和取消
+func (r *ReadWriter) ReadToEndLineContext(ctx context.Context, onOut func(lines []string), interceptors ...interceptor.Interceptor) error {
+	return r.Read(ctx, true, onOut, interceptors...)
+}
+
+// ReadAllContext 与 ReadAll 相同，使用 ctx 控制超时和取消
+func (r *ReadWriter) ReadAllContext(ctx context.Context, onOut func(lines []string), interceptors ...interceptor.Interceptor) error {
+	return r.Read(ctx, false, onOut, interceptors...)
+}
+
+// WriteContext 与 Write 相同，ctx 超时或取消时中断阻塞的写操作，参考 SetWriteInterrupter
+func (r *ReadWriter) WriteContext(ctx context.Context, cmd string) error {
+	return r.WriteRawContext(ctx, []byte(withLineEnd(cmd)))
+}
+
+// SetWriteInterrupter 设置中断阻塞的写操作的函数
+//
+//	ctx 超时或取消时，如果输入流支持 SetWriteDeadline（如 TelnetShell，以及 CmdShell 的标准输入管道），通过设置写超时中断写操作；
+//	否则调用 f 中断写操作（如 SshShell 关闭会话），f 需要保证阻塞的写操作能够返回；
+//	都不支持时（如使用 New 创建时传入的自定义输入流，或者平台不支持管道写超时时的 CmdShell），写操作在后台继续执行，立即返回超时或取消的错误，
+//	之后的写操作会等待该写操作返回后再写入，避免同时写入导致内容交错；等待期间 ctx 超时或取消时同样返回超时或取消的错误
+//	（此时如果已经设置了 f，调用 f 中断之前的写操作）
+func (r *ReadWriter) SetWriteInterrupter(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeInterrupter = f
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// write 向输入流写入内容，ctx 超时或取消时中断写操作，写操作被中断时 interrupted 为 true
+func (r *ReadWriter) write(ctx context.Context, b []byte) (n int, err error, interrupted bool) {
+	if !r.waitAbandonedWrite(ctx) {
+		return 0, nil, true
+	}
+	in := r.in
+	if ctx.Done() == nil {
+		n, err = in.Write(b)
+		return n, err, false
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	ch, done := make(chan result, 1), make(chan struct{})
+	go func() {
+		n, err := in.Write(b)
+		ch <- result{n, err}
+		close(done)
+	}()
+
+	var v result
+	select {
+	case v = <-ch:
+		return v.n, v.err, false
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	interrupter := r.writeInterrupter
+	r.mu.Unlock()
+
+	if d, ok := in.(writeDeadliner); ok && d.SetWriteDeadline(time.Now()) == nil {
+		// 写操作开始时可能会重新设置写超时（如 telnet.Client），这里需要反复设置，直到写操作返回
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+	loop:
+		for {
+			select {
+			case v = <-ch:
+				break loop
+			case <-ticker.C:
+				_ = d.SetWriteDeadline(time.Now())
+			}
+		}
+		_ = d.SetWriteDeadline(time.Time{})
+	} else if interrupter != nil {
+		interrupter()
+		v = <-ch
+	} else {
+		// 写操作在后台继续执行，之后的写操作需要等待其返回
+		r.mu.Lock()
+		r.abandonedWrite = done
+		r.mu.Unlock()
+		return 0, nil, true
+	}
+
+	// 中断前写操作已经完成
+	if v.err == nil && v.n == len(b) {
+		return v.n, nil, false
+	}
+	return v.n, v.err, true
+}
+
+// waitAbandonedWrite 等待之前被放弃、仍在后台执行的写操作返回，ctx 结束前没有返回时返回 false
+func (r *ReadWriter) waitAbandonedWrite(ctx context.Context) bool {
+	r.mu.Lock()
+	pending, interrupter := r.abandonedWrite, r.writeInterrupter
+	r.mu.Unlock()
+	if pending == nil {
+		return true
+	}
+
+	select {
+	case <-pending:
+		r.mu.Lock()
+		if r.abandonedWrite == pending {
+			r.abandonedWrite = nil
+		}
+		r.mu.Unlock()
+		return true
+	case <-ctx.Done():
+		if interrupter != nil {
+			interrupter()
+			<-pending
+		}
+		return false
+	}
+}
+
+func ctxError(err error) *Error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Op: "timeout", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Op: "canceled", Err: err}
+	default:
+		return &Error{Op: "read", Err: err}
+	}
+}
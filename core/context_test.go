@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadWriter_ReadContext(t *testing.T) {
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(io.Discard, outR, nil, Config{})
+	defer rw.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	assert.True(t, IsCanceled(rw.ReadToEndLineContext(ctx, nil)))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go func() { _, _ = outW.Write([]byte("a\nb\n")) }()
+	var lines []string
+	assert.True(t, IsTimeout(rw.ReadAllContext(ctx, func(v []string) { lines = append(lines, v...) })))
+	assert.Equal(t, []string{"a", "b"}, lines)
+}
+
+func TestReadWriter_WriteContext(t *testing.T) {
+	// 输入流支持 SetWriteDeadline
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(a, outR, nil, Config{})
+	defer rw.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.True(t, IsTimeout(rw.WriteContext(ctx, "ls")))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 中断后可以继续写入
+	go func() { _, _ = io.ReadFull(b, make([]byte, 3)) }()
+	assert.NoError(t, rw.WriteContext(context.Background(), "ls"))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, IsCanceled(rw.WriteContext(ctx, "ls")))
+}
+
+func TestReadWriter_WriteInterrupter(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(inW, outR, nil, Config{})
+	defer rw.Stop()
+
+	// 输入流不支持 SetWriteDeadline，也没有设置中断函数时，立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.True(t, IsTimeout(rw.WriteContext(ctx, "ls")))
+
+	var interrupted bool
+	rw.SetWriteInterrupter(func() {
+		interrupted = true
+		_ = inR.Close()
+	})
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	assert.True(t, IsTimeout(rw.WriteContext(ctx2, "ls")))
+	assert.True(t, interrupted)
+}
+
+// blockingWriter 写操作阻塞到 release 关闭，记录同时进行的写操作的最大数量
+type blockingWriter struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	release   chan struct{}
+	buf       bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.active++; w.active > w.maxActive {
+		w.maxActive = w.active
+	}
+	w.mu.Unlock()
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.active--
+	return w.buf.Write(p)
+}
+
+func TestReadWriter_WriteAbandoned(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(w, outR, nil, Config{})
+	defer rw.Stop()
+
+	// 无法中断的写操作在后台继续执行
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.True(t, IsTimeout(rw.WriteContext(ctx, "ls")))
+
+	// 之后的写操作等待其返回后再写入
+	errs := make(chan error, 1)
+	go func() { errs <- rw.Write("pwd") }()
+	select {
+	case <-errs:
+		t.Fatal("write should wait for the abandoned write")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(w.release)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, 1, w.maxActive)
+	assert.Equal(t, "ls\npwd\n", w.buf.String())
+}
+
+func TestReadWriter_WriteBlockedCommands(t *testing.T) {
+	// 执行命令的方法在写入阻塞时同样受 ctx 控制
+	w := &blockingWriter{release: make(chan struct{})}
+	defer close(w.release)
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(w, outR, nil, Config{})
+	defer rw.Stop()
+
+	for name, f := range map[string]func(ctx context.Context) error{
+		"Run": func(ctx context.Context) error {
+			_, err := rw.Run(ctx, "ls")
+			return err
+		},
+		"ExecContext": func(ctx context.Context) error {
+			_, err := rw.ExecContext(ctx, "ls")
+			return err
+		},
+		"Stream": func(ctx context.Context) error {
+			lines, errs := rw.Stream(ctx, "ls")
+			for range lines {
+			}
+			return <-errs
+		},
+		"Serial": func(ctx context.Context) error {
+			_, err := rw.Serial().Run(ctx, "ls")
+			return err
+		},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := f(ctx)
+		cancel()
+		assert.True(t, IsTimeout(err), "%s: %v", name, err)
+		assert.Less(t, time.Since(start), time.Second, name)
+	}
+}
@@ -2,11 +2,14 @@ package core
 
 import (
 	"context"
+	"errors"
 	"github.com/3th1nk/easygo/util/strUtil"
 )
 
+// isOpError 是否是指定操作的 Error，包括被包装的 Error（如 ReconnectError 中导致连接断开的错误）
 func isOpError(err error, op string) bool {
-	if v, _ := err.(*Error); v != nil {
+	var v *Error
+	if errors.As(err, &v) {
 		return v.Op == op
 	}
 	return false
@@ -20,8 +23,14 @@ func IsDial(err error) bool { return isOpError(err, "dial") }
 
 func IsAuth(err error) bool { return isOpError(err, "auth") }
 
+func IsHostKey(err error) bool { return isOpError(err, "hostkey") }
+
+func IsDisconnected(err error) bool { return isOpError(err, "disconnected") }
+
+func IsIdle(err error) bool { return isOpError(err, "idle") }
+
 type Error struct {
-	// Op is the operation which caused the error, such as "dial" or "auth".
+	// Op is the operation which caused the error, such as "dial", "auth" or "hostkey".
 	Op string
 	// For operations involving a remote network connection.
 	// like Dial, Read, or Write, Addr is the remote address of that connection.
@@ -42,6 +51,15 @@ func (e *Error) Dial() bool { return e.Op == "dial" }
 // 是否是身份认证错误
 func (e *Error) Auth() bool { return e.Op == "auth" }
 
+// 是否是服务器身份（主机公钥）验证错误
+func (e *Error) HostKey() bool { return e.Op == "hostkey" }
+
+// 是否是连接断开错误
+func (e *Error) Disconnected() bool { return e.Op == "disconnected" }
+
+// 是否是空闲超时错误（超过指定时间没有读取到任何输出）
+func (e *Error) Idle() bool { return e.Op == "idle" }
+
 func (e *Error) Name() string {
 	return "Shell" + strUtil.UcFirst(e.Op) + "Error"
 }
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ExitCodeWrapper 包装命令，使命令执行结束后输出一行 "<sentinel>:<退出码>"，用于 Exec 获取命令的退出码
+//
+//	包装后的命令被回显时，回显内容中不能出现 "<sentinel>:<数字>"，否则会被误认为命令已结束
+type ExitCodeWrapper func(cmd, sentinel string) string
+
+// PosixExitCode 适用于 sh、bash、zsh、ksh 等 POSIX shell
+func PosixExitCode(cmd, sentinel string) string {
+	return cmd + `; echo "` + sentinel + `:$?"`
+}
+
+// FishExitCode 适用于 fish shell
+func FishExitCode(cmd, sentinel string) string {
+	return cmd + `; echo "` + sentinel + `:$status"`
+}
+
+// ExecResult 命令执行结果
+type ExecResult struct {
+	Lines    []string      // 命令输出，不包括命令回显和退出码
+	ExitCode int           // 退出码，未获取到退出码时为 -1
+	Duration time.Duration // 执行耗时
+}
+
+// Exec 执行命令，并获取命令的退出码，参考 ExecContext
+func (r *ReadWriter) Exec(cmd string, timeout time.Duration, interceptors ...interceptor.Interceptor) (*ExecResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return r.ExecContext(ctx, cmd, interceptors...)
+}
+
+// ExecContext 执行命令，并获取命令的退出码
+//
+//	命令使用 Config.ExitCodeWrapper 包装（默认为 PosixExitCode），在命令结束后输出唯一的标记和退出码，
+//	读取到该标记、并且读取到命令行提示符后返回，返回的输出中已移除命令回显和退出码
+func (r *ReadWriter) ExecContext(ctx context.Context, cmd string, interceptors ...interceptor.Interceptor) (*ExecResult, error) {
+	wrap := r.cfg.ExitCodeWrapper
+	if wrap == nil {
+		wrap = PosixExitCode
+	}
+	sentinel := newSentinel()
+	re := regexp.MustCompile(regexp.QuoteMeta(sentinel) + `:(\d+)\s*$`)
+
+	start := time.Now()
+	result := &ExecResult{ExitCode: -1}
+	wrapped := wrap(cmd, sentinel)
+	if err := r.WriteContext(ctx, wrapped); err != nil {
+		result.Duration = time.Since(start)
+		return result, err
+	}
+
+	var lines []string
+	var found bool
+	for !found {
+		var n int
+		err := r.Read(ctx, true, func(v []string) {
+			n += len(v)
+			lines = append(lines, v...)
+		}, interceptors...)
+		result.Lines, result.ExitCode, found = parseExecLines(lines, wrapped, sentinel, re)
+		result.Duration = time.Since(start)
+		if err != nil {
+			return result, err
+		}
+		// 命令输出中可能有类似提示符的内容，未读取到退出码时继续读取；没有读取到任何内容时（如输出流已结束）返回错误
+		if !found && n == 0 {
+			return result, &Error{Op: "exec", Err: fmt.Errorf("exit code not found")}
+		}
+	}
+	return result, nil
+}
+
+// newSentinel 生成唯一的标记
+func newSentinel() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "__EASYSHELL_" + strconv.FormatInt(time.Now().UnixNano(), 36) + "__"
+	}
+	return "__EASYSHELL_" + hex.EncodeToString(b) + "__"
+}
+
+// parseExecLines 从输出中解析退出码，移除命令回显和退出码所在的行
+//
+//	echo 为包装后的命令，终端宽度较小时回显会被折行、标记被拆分到多行中，因此按拼接后的内容查找回显的结尾，
+//	回显之前的行（如虚拟终端中与回显在同一行的提示符）一同移除；没有找到完整的回显时，移除包含标记的行及之前的行
+func parseExecLines(lines []string, echo, sentinel string, re *regexp.Regexp) (out []string, exitCode int, found bool) {
+	begin := echoEnd(lines, echo, re)
+	for i := begin; i < len(lines); i++ {
+		line := lines[i]
+		if m := re.FindStringSubmatchIndex(line); m != nil {
+			exitCode, _ = strconv.Atoi(line[m[2]:m[3]])
+			out = append(out, lines[begin:i]...)
+			// 命令输出的最后一行没有换行符时，退出码会紧跟在输出后面
+			if prefix := line[:m[0]]; prefix != "" {
+				out = append(out, prefix)
+			}
+			return out, exitCode, true
+		}
+		if strings.Contains(line, sentinel) {
+			begin = i + 1
+		}
+	}
+	return append(out, lines[begin:]...), -1, false
+}
+
+// echoEnd 返回命令回显之后的第一行的下标，没有找到完整的回显时返回 0
+//
+//	拼接时忽略空白字符（折行处可能会插入空格），回显只会出现在输出的开头，拼接的内容超过回显长度较多时停止查找
+func echoEnd(lines []string, echo string, re *regexp.Regexp) int {
+	want := removeSpaces(echo)
+	if want == "" {
+		return 0
+	}
+	var joined strings.Builder
+	for i, line := range lines {
+		if re.MatchString(line) {
+			break
+		}
+		joined.WriteString(removeSpaces(line))
+		if strings.Contains(joined.String(), want) {
+			return i + 1
+		}
+		if joined.Len() > 2*len(want)+1024 {
+			break
+		}
+	}
+	return 0
+}
+
+func removeSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPosixShell 模拟 POSIX shell：回显输入的命令，支持 "cmd; echo "xxx:$?"" 格式的命令
+//
+//	true: 退出码 0
+//	false: 退出码 1
+//	echo xxx: 输出 xxx
+//	printf xxx: 输出 xxx，末尾没有换行符
+//	fakeprompt: 输出类似提示符的内容，停顿一段时间后继续输出
+//	confirm: 要求输入 y/n，并输出输入的内容
+//	hang: 输出一行内容后不再输出提示符
+//	invalid: 输出类似网络设备的错误提示
+//	ping: 每 100 毫秒输出一行，共输出 10 行
+func newTestPosixShell(t *testing.T, cfg ...Config) *ReadWriter {
+	return newTestPosixShellWidth(t, 0, cfg...)
+}
+
+// newTestPosixShellWidth 模拟终端宽度为 width 的 shell，回显超过宽度时折行，width 为 0 时不折行
+func newTestPosixShellWidth(t *testing.T, width int, cfg ...Config) *ReadWriter {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	t.Cleanup(func() {
+		_ = inR.Close()
+		_ = outW.Close()
+	})
+	re := regexp.MustCompile(`^(.*); echo "(.*):\$\?"$`)
+	go func() {
+		_, _ = outW.Write([]byte("dev$ "))
+		scanner := bufio.NewScanner(inR)
+		for scanner.Scan() {
+			line := scanner.Text()
+			echo := line
+			for width > 0 && len(echo) > width {
+				_, _ = outW.Write([]byte(echo[:width] + "\r\n"))
+				echo = echo[width:]
+			}
+			_, _ = outW.Write([]byte(echo + "\r\n"))
+			cmd, sentinel := line, ""
+			if m := re.FindStringSubmatch(line); m != nil {
+				cmd, sentinel = m[1], m[2]
+			}
+			code := "0"
+			switch {
+			case cmd == "false":
+				code = "1"
+			case strings.HasPrefix(cmd, "echo "):
+				_, _ = outW.Write([]byte(strings.TrimPrefix(cmd, "echo ") + "\r\n"))
+			case strings.HasPrefix(cmd, "printf "):
+				_, _ = outW.Write([]byte(strings.TrimPrefix(cmd, "printf ")))
+			case cmd == "ping":
+				for i := 0; i < 10; i++ {
+					time.Sleep(100 * time.Millisecond)
+					_, _ = outW.Write([]byte("reply\r\n"))
+				}
+			case cmd == "invalid":
+				_, _ = outW.Write([]byte("% Invalid input detected at '^' marker.\r\n"))
+			case cmd == "hang":
+				_, _ = outW.Write([]byte("working\r\n"))
+				continue
+			case cmd == "confirm":
+				_, _ = outW.Write([]byte("Continue? [y/n]: "))
+				if !scanner.Scan() {
+					return
+				}
+				_, _ = outW.Write([]byte(scanner.Text() + "\r\nanswer: " + scanner.Text() + "\r\n"))
+			case cmd == "fakeprompt":
+				_, _ = outW.Write([]byte("[root@fake ~]# "))
+				time.Sleep(600 * time.Millisecond)
+				_, _ = outW.Write([]byte("\r\nafter\r\n"))
+			}
+			if sentinel != "" {
+				_, _ = outW.Write([]byte(sentinel + ":" + code + "\r\n"))
+			}
+			_, _ = outW.Write([]byte("dev$ "))
+		}
+	}()
+	var c Config
+	if len(cfg) != 0 {
+		c = cfg[0]
+	}
+	rw := New(inW, outR, nil, c)
+	t.Cleanup(rw.Stop)
+	assert.NoError(t, rw.ReadToEndLine(time.Second, nil))
+	return rw
+}
+
+func TestReadWriter_Exec(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	r, err := rw.Exec("echo hello", 5*time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"hello"}, r.Lines)
+		assert.Equal(t, 0, r.ExitCode)
+		assert.Greater(t, r.Duration, time.Duration(0))
+	}
+
+	r, err = rw.Exec("false", 5*time.Second)
+	if assert.NoError(t, err) {
+		assert.Empty(t, r.Lines)
+		assert.Equal(t, 1, r.ExitCode)
+	}
+
+	// 最后一行没有换行符
+	r, err = rw.Exec("printf abc", 5*time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"abc"}, r.Lines)
+		assert.Equal(t, 0, r.ExitCode)
+	}
+
+	// 输出中类似提示符的内容不会导致提前返回
+	r, err = rw.Exec("fakeprompt", 5*time.Second)
+	if assert.NoError(t, err) {
+		assert.Contains(t, r.Lines, "after")
+		assert.Equal(t, 0, r.ExitCode)
+	}
+}
+
+func TestReadWriter_ExecNarrowTerminal(t *testing.T) {
+	// 终端宽度较小时，回显被折行，标记被拆分到多行中
+	rw := newTestPosixShellWidth(t, 20)
+
+	r, err := rw.Exec("echo hello", 5*time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"hello"}, r.Lines)
+		assert.Equal(t, 0, r.ExitCode)
+	}
+}
+
+func TestParseExecLines(t *testing.T) {
+	sentinel := "__S__"
+	re := regexp.MustCompile(regexp.QuoteMeta(sentinel) + `:(\d+)\s*$`)
+
+	echo := `ls; echo "__S__:$?"`
+
+	out, code, found := parseExecLines([]string{`$ ls; echo "__S__:$?"`, "a", "b", "__S__:2"}, echo, sentinel, re)
+	assert.Equal(t, []string{"a", "b"}, out)
+	assert.Equal(t, 2, code)
+	assert.True(t, found)
+
+	// 没有回显
+	out, code, found = parseExecLines([]string{"a", "b__S__:0"}, echo, sentinel, re)
+	assert.Equal(t, []string{"a", "b"}, out)
+	assert.Equal(t, 0, code)
+	assert.True(t, found)
+
+	out, code, found = parseExecLines([]string{`$ ls; echo "__S__:$?"`, "a"}, echo, sentinel, re)
+	assert.Equal(t, []string{"a"}, out)
+	assert.Equal(t, -1, code)
+	assert.False(t, found)
+
+	// 回显被折行，标记被拆分到多行中
+	out, code, found = parseExecLines([]string{`$ ls; echo "__`, `S__:$?"`, "a", "__S__:0"}, echo, sentinel, re)
+	assert.Equal(t, []string{"a"}, out)
+	assert.Equal(t, 0, code)
+	assert.True(t, found)
+	out, code, found = parseExecLines([]string{`$ ls; ec`, `ho "__S_`, `_:$?"`, "a"}, echo, sentinel, re)
+	assert.Equal(t, []string{"a"}, out)
+	assert.Equal(t, -1, code)
+	assert.False(t, found)
+}
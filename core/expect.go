@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/internal/misc"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExpectCase Expect 的匹配规则
+type ExpectCase struct {
+	Regex    *regexp.Regexp // 匹配规则
+	LastLine bool           // 为 true 时只匹配最后一行，否则匹配 Expect 开始后读取到的全部内容
+	Prompt   bool           // 为 true 时最后一行是命令行提示符时匹配（参考 IsEndLine），忽略 Regex、LastLine
+}
+
+// ExpectRegex 匹配 Expect 开始后读取到的全部内容
+func ExpectRegex(pattern string) ExpectCase {
+	return ExpectCase{Regex: regexp.MustCompile(pattern)}
+}
+
+// ExpectLastLine 只匹配最后一行
+func ExpectLastLine(pattern string) ExpectCase {
+	return ExpectCase{Regex: regexp.MustCompile(pattern), LastLine: true}
+}
+
+// ExpectPrompt 匹配命令行提示符
+func ExpectPrompt() ExpectCase {
+	return ExpectCase{Prompt: true}
+}
+
+// ExpectResult Expect 的匹配结果
+type ExpectResult struct {
+	Index  int      // 匹配的规则下标
+	Match  []string // 匹配的内容，Match[0] 为完整匹配的内容，之后为各个分组匹配的内容
+	Before string   // 匹配内容之前的输出
+	After  string   // 已读取到的、匹配内容之后的输出（不包括尚未结束的最后一行，该行会保留给后续的读取操作）
+}
+
+// Expect 读取输出，直到匹配任意一个规则，返回匹配的规则下标、分组匹配的内容以及匹配内容之前的输出，类似 Tcl expect、pexpect
+//
+//	多个规则同时匹配时，返回下标最小的规则；没有规则匹配时，仍然会处理内置的拦截器（自动翻页、继续执行）
+//	输出流结束时仍未匹配，返回 Op 为 read 的错误
+func (r *ReadWriter) Expect(ctx context.Context, cases ...ExpectCase) (*ExpectResult, error) {
+	if err := r.Disconnected(); err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(r.cfg.ReadConfirmWait)
+	defer ticker.Stop()
+
+	done := r.doneChan()
+	idle := r.newIdleWatch()
+
+	// 已读取到的完整的行，每行以 \n 结尾
+	var buf strings.Builder
+	for {
+		select {
+		case <-done:
+			return nil, r.Disconnected()
+		case <-ctx.Done():
+			return nil, ctxError(ctx.Err())
+		case <-ticker.C:
+			if err := idle.check(); err != nil {
+				return nil, err
+			}
+		}
+
+		var result *ExpectResult
+		_, err := r.out.PopLines(func(lines []string, remaining string) (dropRemaining bool) {
+			for _, line := range lines {
+				buf.WriteString(line)
+				buf.WriteByte('\n')
+			}
+			var end int
+			if result, end = r.matchExpect(buf.String(), remaining, cases); result != nil {
+				// 匹配的内容包括最后一行时，最后一行已被使用，否则保留给后续的读取操作
+				if end > buf.Len() {
+					result.After = remaining[end-buf.Len():]
+					return true
+				}
+				result.After = buf.String()[end:]
+				return false
+			}
+
+			for _, f := range defaultInterceptors {
+				if match, showOut, input := f(remaining); match {
+					if showOut {
+						buf.WriteString(remaining)
+						buf.WriteByte('\n')
+					}
+					_ = r.WriteRawContext(ctx, []byte(input))
+					return true
+				}
+			}
+			return false
+		})
+		if result != nil {
+			return result, nil
+		}
+		if err != nil {
+			return nil, &Error{Op: "read", Err: err}
+		}
+	}
+}
+
+// matchExpect 依次使用各个规则匹配 lines + remaining，返回匹配结果以及匹配内容的结束位置
+func (r *ReadWriter) matchExpect(lines, remaining string, cases []ExpectCase) (*ExpectResult, int) {
+	text := lines + remaining
+
+	// 最后一行：remaining 不为空时为 remaining，否则为最后一个完整的行
+	lastLine, lastStart := remaining, len(lines)
+	if remaining == "" && lines != "" {
+		s := lines[:len(lines)-1]
+		lastStart = strings.LastIndexByte(s, '\n') + 1
+		lastLine = s[lastStart:]
+	}
+
+	for i, c := range cases {
+		switch {
+		case c.Prompt:
+			if lastLine != "" && r.IsEndLine(lastLine) {
+				return &ExpectResult{Index: i, Match: []string{lastLine}, Before: text[:lastStart]}, lastStart + len(lastLine)
+			}
+		case c.Regex == nil:
+		case c.LastLine:
+			if loc := c.Regex.FindStringSubmatchIndex(lastLine); loc != nil {
+				return newExpectResult(i, text, loc, lastStart), lastStart + loc[1]
+			}
+		default:
+			if loc := c.Regex.FindStringSubmatchIndex(text); loc != nil {
+				return newExpectResult(i, text, loc, 0), loc[1]
+			}
+		}
+	}
+	return nil, 0
+}
+
+func newExpectResult(index int, text string, loc []int, offset int) *ExpectResult {
+	match := make([]string, len(loc)/2)
+	for i := range match {
+		if loc[2*i] >= 0 {
+			match[i] = text[offset+loc[2*i] : offset+loc[2*i+1]]
+		}
+	}
+	return &ExpectResult{Index: index, Match: match, Before: text[:offset+loc[0]]}
+}
+
+// BeforeLines 将 Before 拆分为行，并移除前后的空行
+func (e *ExpectResult) BeforeLines() []string {
+	return misc.TrimEmptyLine(strings.Split(strings.TrimSuffix(e.Before, "\n"), "\n"))
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadWriter_Expect(t *testing.T) {
+	rw := newTestPosixShell(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cases := []ExpectCase{ExpectRegex(`% Invalid input (\w+) at`), ExpectPrompt()}
+
+	assert.NoError(t, rw.Write("invalid"))
+	r, err := rw.Expect(ctx, cases...)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, r.Index)
+		assert.Equal(t, []string{"% Invalid input detected at", "detected"}, r.Match)
+		assert.Equal(t, "invalid\n", r.Before)
+		assert.Equal(t, []string{"invalid"}, r.BeforeLines())
+	}
+	// 匹配内容之后的提示符保留给后续的读取操作
+	r, err = rw.Expect(ctx, ExpectPrompt())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, r.Index)
+		assert.Equal(t, []string{"dev$ "}, r.Match)
+	}
+
+	assert.NoError(t, rw.Write("echo hello"))
+	r, err = rw.Expect(ctx, cases...)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, r.Index)
+		assert.Equal(t, "echo hello\nhello\n", r.Before)
+	}
+
+	assert.NoError(t, rw.Write("confirm"))
+	r, err = rw.Expect(ctx, ExpectLastLine(`\[(y)/(n)\]:\s*$`), ExpectPrompt())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, r.Index)
+		assert.Equal(t, []string{"[y/n]: ", "y", "n"}, r.Match)
+		assert.Equal(t, "confirm\nContinue? ", r.Before)
+	}
+	assert.NoError(t, rw.Write("n"))
+	r, err = rw.Expect(ctx, ExpectRegex(`answer: (\w)`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "n", r.Match[1])
+	}
+	_, err = rw.Expect(ctx, ExpectPrompt())
+	assert.NoError(t, err)
+
+	assert.NoError(t, rw.Write("hang"))
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel2()
+	_, err = rw.Expect(ctx2, ExpectPrompt())
+	assert.True(t, IsTimeout(err))
+}
+
+func TestReadWriter_MatchExpect(t *testing.T) {
+	var rw ReadWriter
+	cases := []ExpectCase{ExpectLastLine(`^b(\d)$`), ExpectRegex(`a(\d)\n`)}
+
+	// 最后一行没有换行符时，最后一行为 remaining
+	r, end := rw.matchExpect("a1\n", "b2", cases)
+	if assert.NotNil(t, r) {
+		assert.Equal(t, 0, r.Index)
+		assert.Equal(t, []string{"b2", "2"}, r.Match)
+		assert.Equal(t, "a1\n", r.Before)
+		assert.Equal(t, 5, end)
+	}
+
+	// remaining 为空时，最后一行为最后一个完整的行
+	r, end = rw.matchExpect("a1\nb2\n", "", cases)
+	if assert.NotNil(t, r) {
+		assert.Equal(t, 0, r.Index)
+		assert.Equal(t, 5, end)
+	}
+
+	r, end = rw.matchExpect("x\na1\n", "c", cases)
+	if assert.NotNil(t, r) {
+		assert.Equal(t, 1, r.Index)
+		assert.Equal(t, "x\n", r.Before)
+		assert.Equal(t, 5, end)
+	}
+
+	r, _ = rw.matchExpect("x\n", "c", cases)
+	assert.Nil(t, r)
+}
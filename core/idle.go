@@ -0,0 +1,33 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// idleWatch 检测读取过程中是否超过 Config.IdleTimeout 没有读取到任何输出
+type idleWatch struct {
+	r        *ReadWriter
+	timeout  time.Duration
+	bytes    int64
+	activeAt time.Time
+}
+
+func (r *ReadWriter) newIdleWatch() *idleWatch {
+	return &idleWatch{r: r, timeout: r.cfg.IdleTimeout, bytes: atomic.LoadInt64(&r.bytesRead), activeAt: time.Now()}
+}
+
+func (w *idleWatch) check() error {
+	if w.timeout <= 0 {
+		return nil
+	}
+	if n := atomic.LoadInt64(&w.r.bytesRead); n != w.bytes {
+		w.bytes, w.activeAt = n, time.Now()
+		return nil
+	}
+	if time.Since(w.activeAt) >= w.timeout {
+		return &Error{Op: "idle", Err: fmt.Errorf("no output for %v", w.timeout)}
+	}
+	return nil
+}
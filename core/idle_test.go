@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadWriter_IdleTimeout(t *testing.T) {
+	rw := newTestPosixShell(t, Config{IdleTimeout: 500 * time.Millisecond})
+
+	// 总耗时超过空闲超时时间，但持续有输出
+	var lines []string
+	assert.NoError(t, rw.Write("ping"))
+	assert.NoError(t, rw.ReadToEndLine(5*time.Second, func(v []string) { lines = append(lines, v...) }))
+	assert.Len(t, lines, 11)
+
+	// 没有输出时，在总超时时间之前返回
+	assert.NoError(t, rw.Write("hang"))
+	start := time.Now()
+	err := rw.ReadToEndLine(5*time.Second, nil)
+	assert.True(t, IsIdle(err), err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// 总超时时间仍然生效
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	assert.True(t, IsTimeout(rw.ReadToEndLineContext(ctx, nil)))
+
+	_, err = rw.Expect(context.Background(), ExpectPrompt())
+	assert.True(t, IsIdle(err), err)
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"io"
+)
+
+// OutputReader 返回读取输出的 io.Reader，可以配合 bufio.Scanner、io.Copy 等使用
+//
+//	读取的内容为经过过滤、解码后的输出，每行以 \n 结尾，不包括命令行提示符
+//	读取到命令行提示符、或输出流结束时返回 io.EOF，读取失败（如超时、连接断开）时返回对应的错误
+//	调用方读取较慢时暂停读取输出；不再读取时需要调用 Close，Close 之前不能调用 ReadWriter 的其他读取方法
+func (r *ReadWriter) OutputReader(ctx context.Context, interceptors ...interceptor.Interceptor) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := r.readWithReconnect(ctx, &readOptions{
+			stopOnEndLine: true,
+			onOut: func(lines []string) {
+				for _, line := range lines {
+					if _, e := pw.Write([]byte(line + "\n")); e != nil {
+						// 调用方已关闭
+						cancel()
+						return
+					}
+				}
+			},
+			interceptors: interceptors,
+		})
+		_ = pw.CloseWithError(err)
+	}()
+	return &outputReader{PipeReader: pr, cancel: cancel, done: done}
+}
+
+type outputReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Close 停止读取，并等待读取协程退出
+func (o *outputReader) Close() error {
+	o.cancel()
+	err := o.PipeReader.Close()
+	<-o.done
+	return err
+}
+
+// InputWriter 返回写入输入流的 io.WriteCloser，适用于写入大段内容（如粘贴配置），ctx 超时或取消时中断写操作
+//
+//	Close 时如果写入的内容不是以换行符结尾，补充一个换行符；Close 不会关闭底层的输入流
+func (r *ReadWriter) InputWriter(ctx context.Context) io.WriteCloser {
+	return &inputWriter{r: r, ctx: ctx}
+}
+
+type inputWriter struct {
+	r      *ReadWriter
+	ctx    context.Context
+	last   byte
+	closed bool
+}
+
+func (w *inputWriter) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if err := w.r.WriteRawContext(w.ctx, b); err != nil {
+		return 0, err
+	}
+	w.last = b[len(b)-1]
+	return len(b), nil
+}
+
+func (w *inputWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.last != 0 && w.last != '\n' {
+		return w.r.WriteRawContext(w.ctx, []byte("\n"))
+	}
+	return nil
+}
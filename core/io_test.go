@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadWriter_OutputReader(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	assert.NoError(t, rw.Write("ping"))
+	out := rw.OutputReader(context.Background())
+	scanner := bufio.NewScanner(out)
+	var lines []string
+	for scanner.Scan() {
+		time.Sleep(20 * time.Millisecond)
+		lines = append(lines, scanner.Text())
+	}
+	assert.NoError(t, scanner.Err())
+	assert.NoError(t, out.Close())
+	assert.Len(t, lines, 11)
+	assert.Equal(t, "ping", lines[0])
+	assert.Equal(t, "reply", lines[10])
+
+	assert.NoError(t, rw.Write("echo hello"))
+	out = rw.OutputReader(context.Background())
+	b, err := io.ReadAll(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hello\nhello\n", string(b))
+	assert.NoError(t, out.Close())
+
+	// 读取失败时返回对应的错误
+	assert.NoError(t, rw.Write("hang"))
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	out = rw.OutputReader(ctx)
+	b, err = io.ReadAll(out)
+	assert.True(t, IsTimeout(err), err)
+	assert.Equal(t, "hang\nworking\n", string(b))
+	assert.NoError(t, out.Close())
+}
+
+func TestReadWriter_OutputReaderClose(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	assert.NoError(t, rw.Write("ping"))
+	out := rw.OutputReader(context.Background())
+	line, err := bufio.NewReader(out).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "ping\n", line)
+
+	// 未读取完毕时关闭
+	start := time.Now()
+	assert.NoError(t, out.Close())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestReadWriter_InputWriter(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	in := rw.InputWriter(context.Background())
+	_, err := io.Copy(in, strings.NewReader("echo a\necho b\necho c"))
+	assert.NoError(t, err)
+	// Close 时补充换行符
+	assert.NoError(t, in.Close())
+	_, err = in.Write([]byte("echo d\n"))
+	assert.Equal(t, io.ErrClosedPipe, err)
+
+	// 粘贴的内容被依次执行
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := rw.Expect(ctx, ExpectRegex(`(?m)^c$`))
+	assert.NoError(t, err)
+	if assert.NotNil(t, res) {
+		assert.Contains(t, res.Before, "\na\n")
+		assert.Contains(t, res.Before, "\nb\n")
+	}
+}
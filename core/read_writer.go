@@ -8,10 +8,13 @@ import (
 	"github.com/3th1nk/easyshell/internal/lazyOut"
 	"github.com/3th1nk/easyshell/internal/lineReader"
 	"github.com/3th1nk/easyshell/internal/misc"
+	"github.com/3th1nk/easyshell/pkg/charset"
 	"github.com/3th1nk/easyshell/pkg/interceptor"
 	"io"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -39,31 +42,71 @@ func New(in io.Writer, out, err io.Reader, cfg Config) *ReadWriter {
 	if !misc.IsNil(cfg.RawOut) {
 		opts = append(opts, lineReader.WithRawOut(cfg.RawOut))
 	}
-	if !misc.IsNil(cfg.Filter) {
+	if cfg.Filter != nil {
 		opts = append(opts, lineReader.WithFilter(cfg.Filter))
 	}
-	if cfg.Decoder != nil {
-		opts = append(opts, lineReader.WithDecoder(cfg.Decoder))
+	if cfg.Decoder == nil {
+		// 同一个会话的 stdout、stderr 以及重连后的输出共享编码识别结果
+		cfg.Decoder = charset.Auto()
+	}
+	opts = append(opts, lineReader.WithDecoder(cfg.Decoder))
+	if cfg.Renderer != nil {
+		opts = append(opts, lineReader.WithRenderer(cfg.Renderer))
 	}
 
 	r := &ReadWriter{
-		in:  in,
-		out: lineReader.New(out, opts...),
-		err: lineReader.New(err, opts...),
-		cfg: cfg,
+		in:   in,
+		cfg:  cfg,
+		opts: opts,
+		done: make(chan struct{}),
 	}
+	r.out, r.err = r.newLineReader(out), r.newLineReader(err)
 	if cfg.LazyOutInterval > 0 || cfg.LazyOutSize > 0 {
 		r.lo = lazyOut.New(cfg.LazyOutInterval, cfg.LazyOutSize)
 	}
 	return r
 }
 
+// ReadWriter 读写交互式命令行
+//
+//	ReadWriter 不是并发安全的：多个协程同时调用 Write + ReadToEndLine 时输出会相互交错，多个协程共享同一个会话时使用 Serial
 type ReadWriter struct {
-	cfg      Config
-	in       io.Writer
-	out, err *lineReader.LineReader
-	lo       *lazyOut.LazyOut
-	prompt   string
+	cfg          Config
+	opts         []lineReader.Option
+	in           io.Writer
+	out, err     *lineReader.LineReader
+	lo           *lazyOut.LazyOut
+	prompt       string
+	mu           sync.Mutex
+	done         chan struct{} // 连接断开时关闭
+	doneErr      *Error        // 连接断开的原因
+	connector    Connector     // 重新建立连接的函数，参考 SetConnector
+	reconnecting bool          // 是否正在重新连接
+	bytesRead    int64         // 从输出流中读取的字节数
+	bytesWritten int64         // 写入输入流的字节数
+	// 中断阻塞的写操作的函数，参考 SetWriteInterrupter
+	writeInterrupter func()
+	abandonedWrite   chan struct{} // 被放弃、仍在后台执行的写操作，返回时关闭，参考 SetWriteInterrupter
+	serial           *Serial       // 串行执行命令的队列，参考 Serial
+}
+
+// newLineReader 创建 lineReader，同时统计读取的字节数
+func (r *ReadWriter) newLineReader(rd io.Reader) *lineReader.LineReader {
+	if misc.IsNil(rd) {
+		return nil
+	}
+	return lineReader.New(&countReader{r: rd, n: &r.bytesRead}, r.opts...)
+}
+
+type countReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
 }
 
 func (r *ReadWriter) Stop() {
@@ -74,26 +117,91 @@ func (r *ReadWriter) Stop() {
 	r.in, r.out, r.err = nil, nil, nil
 }
 
+// Disconnect 标记连接已断开（如心跳检测失败），正在进行以及之后的读写操作会立即返回 Op 为 disconnected 的错误
+func (r *ReadWriter) Disconnect(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.doneErr != nil {
+		return
+	}
+	if v, _ := err.(*Error); v != nil && v.Disconnected() {
+		r.doneErr = v
+	} else {
+		r.doneErr = &Error{Op: "disconnected", Err: err}
+	}
+	close(r.done)
+}
+
+// Disconnected 返回连接断开的错误，连接未断开时返回 nil
+func (r *ReadWriter) Disconnected() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.doneErr != nil {
+		return r.doneErr
+	}
+	return nil
+}
+
+func (r *ReadWriter) doneChan() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
 // Write 写入一个命令（自动在末尾补充 \n 换行符）。
 func (r *ReadWriter) Write(cmd string) (err error) {
+	return r.WriteRaw([]byte(withLineEnd(cmd)))
+}
+
+func withLineEnd(cmd string) string {
 	if cmd == "" {
-		cmd = "\n"
+		return "\n"
 	} else if cmd[len(cmd)-1] != '\n' {
-		cmd += "\n"
+		return cmd + "\n"
 	}
-	return r.WriteRaw([]byte(cmd))
+	return cmd
 }
 
-// WriteRaw 向输入流写入指定内容，并等待指定时间（默认 10 毫秒）。
+// WriteRaw 向输入流写入指定内容。
+//
+//	如果启用了自动重连，连接已断开时会先重新连接再写入；写入失败时会重新连接，并返回可以安全重试的 ReconnectError。
 func (r *ReadWriter) WriteRaw(b []byte) (err error) {
+	return r.WriteRawContext(context.Background(), b)
+}
+
+// WriteRawContext 与 WriteRaw 相同，ctx 超时或取消时中断阻塞的写操作，参考 SetWriteInterrupter
+func (r *ReadWriter) WriteRawContext(ctx context.Context, b []byte) (err error) {
+	if err = ctx.Err(); err != nil {
+		return ctxError(err)
+	}
+	if err = r.Disconnected(); err != nil {
+		if !r.canReconnect() {
+			return err
+		}
+		if err = r.reconnect(ctx, err); err != nil {
+			return &ReconnectError{Err: err, Retryable: true}
+		}
+	}
 	if len(b) != 0 {
-		_, err = r.in.Write(b)
+		var n int
+		var interrupted bool
+		n, err, interrupted = r.write(ctx, b)
+		atomic.AddInt64(&r.bytesWritten, int64(n))
+		if interrupted {
+			return ctxError(ctx.Err())
+		}
+		if err != nil && r.canReconnect() {
+			e := r.reconnect(ctx, err)
+			return &ReconnectError{Err: err, Retryable: true, Reconnected: e == nil}
+		}
 	}
-	return nil
+	return err
 }
 
 // Prompt 命令交互过程中提示符可能发生变化，该方法获取最新的提示符
 func (r *ReadWriter) Prompt() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.prompt
 }
 
@@ -109,41 +217,93 @@ func (r *ReadWriter) ReadAll(timeout time.Duration, onOut func(lines []string),
 	return r.Read(ctx, false, onOut, interceptors...)
 }
 
+// Read 读取输出内容，直到 ctx 结束、输出流结束，或者 stopOnEndLine 为 true 时读取到命令行提示符。
+//
+//	如果启用了自动重连，读取过程中连接断开（包括 stopOnEndLine 为 true 时未读取到提示符输出流就结束了）时会重新连接，
+//	并返回不可安全重试的 ReconnectError（命令可能已经执行）。
 func (r *ReadWriter) Read(ctx context.Context, stopOnEndLine bool, onOut func(lines []string), interceptors ...interceptor.Interceptor) (err error) {
+	return r.readWithReconnect(ctx, &readOptions{stopOnEndLine: stopOnEndLine, onOut: onOut, interceptors: interceptors})
+}
+
+// readWithReconnect 读取输出，启用自动重连时，连接断开后自动重连
+func (r *ReadWriter) readWithReconnect(ctx context.Context, o *readOptions) (err error) {
+	if err = r.read(ctx, o); err != nil && r.canReconnect() && IsDisconnected(err) {
+		e := r.reconnect(ctx, err)
+		return &ReconnectError{Err: err, Reconnected: e == nil}
+	}
+	return err
+}
+
+type readOptions struct {
+	stopOnEndLine bool                      // 读取到命令行提示符时结束
+	onOut         func(lines []string)      // 输出流的内容
+	onErrOut      func(lines []string)      // 错误输出流的内容，不为 nil 时实时读取错误输出流；为 nil 时在读取结束后读取，并作为错误返回
+	interceptors  []interceptor.Interceptor // 拦截器
+	// 拦截器触发时的回调，index 为拦截器的下标（内置的默认拦截器为 -1），out 为触发拦截器的输出内容，input 为拦截器自动输入的内容
+	onIntercept func(index int, out, input string)
+}
+
+func (r *ReadWriter) read(ctx context.Context, o *readOptions) (err error) {
+	stopOnEndLine, onOut, onIntercept, interceptors := o.stopOnEndLine, o.onOut, o.onIntercept, o.interceptors
+	if err = r.Disconnected(); err != nil {
+		return err
+	}
 	if r.cfg.BeforeRead != nil {
 		if err = r.cfg.BeforeRead(); err != nil {
 			return err
 		}
 	}
 
-	if r.lo != nil {
-		r.lo.SetOut(onOut)
-		onOut = r.lo.Add
+	if lo := r.lo; lo != nil {
+		lo.SetOut(onOut)
+		onOut = lo.Add
+		// 任何情况下返回（包括 ctx 结束、空闲超时、连接断开）都输出缓冲区中的内容并清除 onOut，避免读取结束后仍然调用本次读取的 onOut
+		defer lo.Detach()
 	}
 
 	ticker := time.NewTicker(r.cfg.ReadConfirmWait)
 	defer ticker.Stop()
 
+	done := r.doneChan()
+	idle := r.newIdleWatch()
+
 	var outBuf strings.Builder
 	var stop bool
 	var confirm int
+	// 读取到的提示符，stopOnEndLine 为 true 时在确认输出结束前保留在缓冲区中：
+	//	提示符之后可能还有同一行的输出（如分包时将 "12:00:00" 中的 "12:" 误判为提示符），此时提示符是输出内容的一部分
+	var prompt string
+	defer func() {
+		if stop && !r.cfg.ShowPrompt {
+			r.out.DropRemaining(prompt)
+		}
+	}()
 	for {
 		select {
+		case <-done:
+			return r.Disconnected()
+
 		case <-ctx.Done():
-			switch err = ctx.Err(); {
-			default:
-				return &Error{Op: "read", Err: err}
-			case errors.Is(err, context.DeadlineExceeded):
-				return &Error{Op: "timeout", Err: err}
-			case errors.Is(err, context.Canceled):
-				return &Error{Op: "canceled", Err: err}
-			}
+			return ctxError(ctx.Err())
 
 		case <-ticker.C:
-			_, e := r.out.PopLines(func(lines []string, remaining string) (dropRemaining bool) {
+			// 已读取到提示符、正在确认输出是否结束时，不检测空闲超时
+			if !stop {
+				if err = idle.check(); err != nil {
+					return err
+				}
+			}
+			if o.onErrOut != nil && r.err != nil {
+				r.popErrOut(o.onErrOut)
+			}
+			_, e := r.out.PopRenderedLines(func(lines []string, remaining string, rendered []string, renderedRemaining string) (dropRemaining bool) {
+				// 正在确认输出是否结束，没有新的输出
+				if stop && len(lines) == 0 && remaining == prompt {
+					return false
+				}
 				stop = false
-				if len(lines) != 0 && onOut != nil {
-					onOut(lines)
+				if len(rendered) != 0 && onOut != nil {
+					onOut(rendered)
 				}
 
 				// 匹配优先级：指定的拦截器规则 > 默认拦截器规则 > 命令结束提示符规则
@@ -156,12 +316,15 @@ func (r *ReadWriter) Read(ctx context.Context, stopOnEndLine bool, onOut func(li
 						outBuf.WriteString("\n")
 						outBuf.WriteString(remaining)
 					}
-					for _, f := range interceptors {
+					for i, f := range interceptors {
 						if match, showOut, input := f(outBuf.String()); match {
 							//util.PrintTimeLn("interceptor matched: %v => %v", outBuf.String(), input)
+							if onIntercept != nil {
+								onIntercept(i, outBuf.String(), input)
+							}
 							outBuf.Reset()
 							// TODO 如果是匹配多行内容的拦截器，前面行的内容总是被返回了，后续优化
-							_ = r.Write(input) // 这里自动加了 \n
+							_ = r.WriteContext(ctx, input) // 这里自动加了 \n
 							return !showOut
 						}
 					}
@@ -174,11 +337,14 @@ func (r *ReadWriter) Read(ctx context.Context, stopOnEndLine bool, onOut func(li
 				// 默认拦截器规则
 				for _, f := range defaultInterceptors {
 					if match, showOut, input := f(remaining); match {
+						if onIntercept != nil {
+							onIntercept(-1, remaining, input)
+						}
 						outBuf.Reset()
 						if showOut && onOut != nil {
-							onOut([]string{remaining})
+							onOut([]string{renderedRemaining})
 						}
-						_ = r.WriteRaw([]byte(input))
+						_ = r.WriteRawContext(ctx, []byte(input))
 						return !showOut
 					}
 				}
@@ -192,8 +358,13 @@ func (r *ReadWriter) Read(ctx context.Context, stopOnEndLine bool, onOut func(li
 							util.PrintTimeLn("prompt:" + remaining + ", correct prompt regex:" + re.String())
 						}
 					}
+					r.mu.Lock()
 					r.prompt = remaining
-					stop = stopOnEndLine
+					r.mu.Unlock()
+					if stop = stopOnEndLine; stop {
+						prompt = remaining
+						return false
+					}
 					return !r.cfg.ShowPrompt
 				}
 
@@ -202,7 +373,14 @@ func (r *ReadWriter) Read(ctx context.Context, stopOnEndLine bool, onOut func(li
 			if e != nil {
 				// 保留 err 后退出循环，继续后续的 err.PopLines
 				if e != io.EOF && !errors.Is(e, io.ErrClosedPipe) && !errors.Is(e, io.ErrNoProgress) && !errors.Is(e, io.ErrUnexpectedEOF) {
-					err = &Error{Op: "read", Err: e}
+					if r.canReconnect() {
+						err = &Error{Op: "disconnected", Err: e}
+					} else {
+						err = &Error{Op: "read", Err: e}
+					}
+				} else if stopOnEndLine && !stop && r.canReconnect() {
+					// 启用自动重连时，未读取到提示符输出流就结束了，认为连接已断开
+					err = &Error{Op: "disconnected", Err: e}
 				}
 				goto exit
 			}
@@ -221,7 +399,9 @@ func (r *ReadWriter) Read(ctx context.Context, stopOnEndLine bool, onOut func(li
 	}
 
 exit:
-	if r.err != nil {
+	if o.onErrOut != nil && r.err != nil {
+		r.popErrOut(o.onErrOut)
+	} else if r.err != nil {
 		confirm = 0
 		var errBuf strings.Builder
 		for {
@@ -258,13 +438,26 @@ exit:
 		}
 	}
 
-	if r.lo != nil {
-		r.lo.Out()
-	}
-
 	return
 }
 
+// popErrOut 取出错误输出流中已读取到的行，错误输出流结束时同时取出最后一行（没有换行符）
+func (r *ReadWriter) popErrOut(onErrOut func(lines []string)) {
+	ended := r.err.Err() != nil
+	var out []string
+	_, _ = r.err.PopRenderedLines(func(lines []string, remaining string, rendered []string, renderedRemaining string) (dropRemaining bool) {
+		out = append(out, rendered...)
+		if ended && remaining != "" {
+			out = append(out, renderedRemaining)
+			return true
+		}
+		return false
+	})
+	if len(out) != 0 {
+		onErrOut(out)
+	}
+}
+
 func (r *ReadWriter) IsEndLine(s string) bool {
 	var matched bool
 	if len(r.cfg.PromptRegex) != 0 {
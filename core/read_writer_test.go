@@ -1,8 +1,15 @@
 package core
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultPromptRegex(t *testing.T) {
@@ -62,3 +69,165 @@ func TestFindHostname(t *testing.T) {
 		assert.Equal(t, obj.Hostname, findHostname(obj.Remaining))
 	}
 }
+
+func TestReadWriter_Disconnect(t *testing.T) {
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(io.Discard, outR, nil, Config{})
+	defer rw.Stop()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		rw.Disconnect(fmt.Errorf("broken pipe"))
+	}()
+	start := time.Now()
+	err := rw.ReadToEndLine(time.Minute, nil)
+	assert.True(t, IsDisconnected(err))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.True(t, errors.Is(err, rw.Disconnected()))
+	assert.True(t, IsDisconnected(rw.Write("ls")))
+}
+
+// testDevice 模拟一个简单的交互式设备：每收到一行命令，输出命令本身和提示符
+type testDevice struct {
+	inR      *io.PipeReader
+	outW     *io.PipeWriter
+	commands chan string
+}
+
+func newTestDevice(commands chan string) (dev *testDevice, in io.Writer, out io.Reader) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	dev = &testDevice{inR: inR, outW: outW, commands: commands}
+	go func() {
+		_, _ = outW.Write([]byte("dev$ "))
+		scanner := bufio.NewScanner(inR)
+		for scanner.Scan() {
+			cmd := strings.TrimSpace(scanner.Text())
+			commands <- cmd
+			if cmd == "hang" {
+				continue
+			}
+			_, _ = outW.Write([]byte(cmd + "\ndev$ "))
+		}
+	}()
+	return dev, inW, outR
+}
+
+// Close 模拟连接断开
+func (d *testDevice) Close() {
+	_ = d.inR.Close()
+	_ = d.outW.Close()
+}
+
+func TestReadWriter_Reconnect(t *testing.T) {
+	commands := make(chan string, 16)
+	dev, in, out := newTestDevice(commands)
+	rw := New(in, out, nil, Config{
+		InitCommands: []string{"init"},
+		Reconnect:    ReconnectPolicy{MaxAttempts: 2, Backoff: 10 * time.Millisecond},
+	})
+	defer rw.Stop()
+	var connected int
+	rw.SetConnector(func(ctx context.Context, r *ReadWriter) error {
+		connected++
+		dev, in, out = newTestDevice(commands)
+		r.Reset(in, out, nil)
+		return r.ReadToEndLine(time.Second, nil)
+	})
+	assert.NoError(t, rw.ReadToEndLine(time.Second, nil))
+
+	// 读取输出的过程中连接断开：重连成功，但命令不可重试
+	assert.NoError(t, rw.Write("hang"))
+	assert.Equal(t, "hang", <-commands)
+	dev.Close()
+	err := rw.ReadToEndLine(5*time.Second, nil)
+	if v, _ := err.(*ReconnectError); assert.NotNil(t, v, err) {
+		assert.True(t, v.Reconnected)
+		assert.False(t, v.Retryable)
+	}
+	assert.Equal(t, 1, connected)
+	assert.Equal(t, "init", <-commands)
+
+	// 写入命令时连接断开：重连成功，命令可以重试
+	dev.Close()
+	err = rw.Write("ls")
+	if v, _ := err.(*ReconnectError); assert.NotNil(t, v, err) {
+		assert.True(t, v.Reconnected)
+		assert.True(t, IsRetryable(err))
+	}
+	assert.Equal(t, 2, connected)
+	assert.Equal(t, "init", <-commands)
+
+	var lines []string
+	assert.NoError(t, rw.Write("ls"))
+	assert.NoError(t, rw.ReadToEndLine(time.Second, func(v []string) { lines = append(lines, v...) }))
+	assert.Equal(t, []string{"ls"}, lines)
+}
+
+func TestReadWriter_ReconnectContext(t *testing.T) {
+	commands := make(chan string, 16)
+	dev, in, out := newTestDevice(commands)
+	rw := New(in, out, nil, Config{
+		Reconnect: ReconnectPolicy{MaxAttempts: 5, Backoff: 10 * time.Millisecond},
+	})
+	defer rw.Stop()
+	var connected int
+	rw.SetConnector(func(ctx context.Context, r *ReadWriter) error {
+		// 连接一直无法建立，直到 ctx 结束
+		connected++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.NoError(t, rw.ReadToEndLine(time.Second, nil))
+
+	// ctx 结束时停止重连，返回的 ReconnectError 仍然可以判断导致连接断开的原因
+	dev.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := rw.ReadToEndLineContext(ctx, nil)
+	assert.Less(t, time.Since(start), time.Second)
+	if v, _ := err.(*ReconnectError); assert.NotNil(t, v, err) {
+		assert.False(t, v.Reconnected)
+	}
+	assert.True(t, IsDisconnected(err))
+	assert.Equal(t, 1, connected)
+
+	// 重连的等待时间同样受 ctx 控制
+	rw.cfg.Reconnect.Backoff = time.Minute
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	err = rw.WriteContext(ctx, "ls")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, IsRetryable(err))
+	assert.True(t, IsTimeout(err))
+	assert.Equal(t, 1, connected)
+}
+
+func TestReadWriter_PromptSplitOutput(t *testing.T) {
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(io.Discard, outR, nil, Config{ReadConfirmWait: 20 * time.Millisecond})
+	defer rw.Stop()
+
+	// 输出被拆分到多次读取中，"12:" 被误判为提示符；确认输出结束前同一行有新的输出时，"12:" 仍然是输出内容的一部分
+	go func() {
+		_, _ = outW.Write([]byte("time\r\n12:"))
+		time.Sleep(30 * time.Millisecond)
+		_, _ = outW.Write([]byte("00:00\r\ndev$ "))
+	}()
+	var lines []string
+	assert.NoError(t, rw.ReadToEndLine(time.Second, func(v []string) { lines = append(lines, v...) }))
+	assert.Equal(t, []string{"time", "12:00:00"}, lines)
+	assert.Equal(t, "dev$ ", rw.Prompt())
+
+	// 确认输出结束后丢弃提示符，不会出现在下一次读取的输出中
+	go func() {
+		_, _ = outW.Write([]byte("ok\r\ndev$ "))
+	}()
+	lines = nil
+	assert.NoError(t, rw.ReadToEndLine(time.Second, func(v []string) { lines = append(lines, v...) }))
+	assert.Equal(t, []string{"ok"}, lines)
+}
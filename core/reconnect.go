@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReconnectPolicy 自动重连策略
+type ReconnectPolicy struct {
+	MaxAttempts int           // 每次连接断开后最多尝试重连的次数，为 0 时不自动重连
+	Backoff     time.Duration // 第一次重连前的等待时间，之后每次翻倍，默认值 1 秒
+	MaxBackoff  time.Duration // 重连等待时间的上限，默认值 30 秒
+}
+
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	d, max := p.Backoff, p.MaxBackoff
+	if d <= 0 {
+		d = time.Second
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Connector 重新建立连接，需要在建立连接后调用 ReadWriter.Reset 替换输入输出流
+//
+//	ctx 为触发重连的读写操作的 ctx，ctx 超时或取消时应尽快返回
+//	Connector 中可以调用 ReadWriter 的读写方法（如读取登录后的欢迎信息），此时不会触发自动重连
+type Connector func(ctx context.Context, r *ReadWriter) error
+
+// ReconnectError 连接断开导致命令执行失败
+type ReconnectError struct {
+	// Err 导致连接断开的错误
+	Err error
+	// Retryable 命令是否可以安全地重试：写入命令失败时为 true；命令已写入、但读取输出的过程中连接断开时为 false（命令可能已经执行）
+	Retryable bool
+	// Reconnected 是否已重新连接成功（已执行会话初始化命令），为 false 时表示重连失败，之后的读写操作会再次尝试重连
+	Reconnected bool
+}
+
+func (e *ReconnectError) Error() string {
+	s := "connection lost"
+	if e.Reconnected {
+		s += " and reconnected"
+	}
+	if e.Retryable {
+		s += ", retryable"
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *ReconnectError) Unwrap() error { return e.Err }
+
+// IsRetryable 是否是可以安全重试的 ReconnectError
+func IsRetryable(err error) bool {
+	var v *ReconnectError
+	if errors.As(err, &v) {
+		return v.Retryable
+	}
+	return false
+}
+
+// SetConnector 设置重新建立连接的函数，配合 Config.Reconnect 实现自动重连
+func (r *ReadWriter) SetConnector(f Connector) {
+	r.connector = f
+}
+
+// Reset 替换输入输出流（如重新建立连接后），并清除连接断开的状态
+func (r *ReadWriter) Reset(in io.Writer, out, err io.Reader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.in = in
+	r.abandonedWrite = nil
+	r.out, r.err = r.newLineReader(out), r.newLineReader(err)
+	if r.doneErr != nil {
+		r.done = make(chan struct{})
+		r.doneErr = nil
+	}
+}
+
+// RunInitCommands 依次执行会话初始化命令，参考 Config.InitCommands
+func (r *ReadWriter) RunInitCommands() error {
+	return r.RunInitCommandsContext(context.Background())
+}
+
+// RunInitCommandsContext 与 RunInitCommands 相同，使用 ctx 控制超时和取消，每个命令的超时时间同时受 Config.InitTimeout 限制
+func (r *ReadWriter) RunInitCommandsContext(ctx context.Context) error {
+	timeout := r.cfg.InitTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	for _, cmd := range r.cfg.InitCommands {
+		if err := r.runInitCommand(ctx, cmd, timeout); err != nil {
+			return fmt.Errorf("init command %q: %w", cmd, err)
+		}
+	}
+	return nil
+}
+
+func (r *ReadWriter) runInitCommand(ctx context.Context, cmd string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := r.WriteContext(ctx, cmd); err != nil {
+		return err
+	}
+	return r.ReadToEndLineContext(ctx, nil)
+}
+
+func (r *ReadWriter) canReconnect() bool {
+	return r.connector != nil && !r.reconnecting && r.cfg.Reconnect.MaxAttempts > 0
+}
+
+// reconnect 按照重连策略重新建立连接并执行会话初始化命令，返回最后一次重连失败的错误
+//
+//	ctx 超时或取消时停止重连，返回超时或取消的错误
+func (r *ReadWriter) reconnect(ctx context.Context, cause error) (err error) {
+	r.reconnecting = true
+	defer func() {
+		r.reconnecting = false
+	}()
+
+	err = cause
+	for i := 0; i < r.cfg.Reconnect.MaxAttempts; i++ {
+		if !sleepContext(ctx, r.cfg.Reconnect.backoff(i)) {
+			err = ctxError(ctx.Err())
+			break
+		}
+		if err = r.connector(ctx, r); err != nil {
+			continue
+		}
+		if err = r.RunInitCommandsContext(ctx); err != nil {
+			continue
+		}
+		return nil
+	}
+
+	// 重连失败，标记连接已断开，之后的读写操作会再次尝试重连
+	r.Disconnect(err)
+	return err
+}
+
+// sleepContext 等待 d，ctx 先结束时返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
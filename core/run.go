@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/internal/misc"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Result 命令执行结果
+type Result struct {
+	Command       string         // 执行的命令
+	Echo          string         // 命令回显（通常为 "提示符 + 命令"），没有回显时为空
+	Lines         []string       // 命令输出，已移除命令回显以及前后的空行
+	Prompt        string         // 命令结束后的提示符，未读取到提示符时为空
+	Interceptions []Interception // 执行过程中触发的拦截器
+	Start         time.Time      // 开始执行的时间
+	End           time.Time      // 执行结束的时间
+	BytesWritten  int64          // 写入的字节数，包括命令以及拦截器自动输入的内容
+	BytesRead     int64          // 读取的原始字节数（过滤、解码前）
+}
+
+// Duration 执行耗时
+func (r *Result) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Interception 拦截器触发记录
+type Interception struct {
+	Index  int       // 触发的拦截器在 WithInterceptors 中的下标，内置的默认拦截器（自动翻页、继续执行）为 -1
+	Output string    // 触发拦截器的输出内容
+	Input  string    // 拦截器自动输入的内容
+	Time   time.Time // 触发时间
+}
+
+type runOptions struct {
+	interceptors []interceptor.Interceptor
+	onOut        func(lines []string)
+}
+
+type RunOption func(o *runOptions)
+
+// WithInterceptors 指定拦截器
+func WithInterceptors(interceptors ...interceptor.Interceptor) RunOption {
+	return func(o *runOptions) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// WithOnOut 实时返回读取到的输出（包括命令回显）
+func WithOnOut(onOut func(lines []string)) RunOption {
+	return func(o *runOptions) {
+		o.onOut = onOut
+	}
+}
+
+// Run 执行命令，读取到命令行提示符后返回命令的执行结果
+//
+//	执行失败时（如超时）同时返回已读取到的部分结果
+func (r *ReadWriter) Run(ctx context.Context, cmd string, opts ...RunOption) (*Result, error) {
+	var o runOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	result := &Result{Command: cmd, Start: time.Now()}
+	written, read := atomic.LoadInt64(&r.bytesWritten), atomic.LoadInt64(&r.bytesRead)
+	var lines []string
+	finish := func(err error) (*Result, error) {
+		result.End = time.Now()
+		result.BytesWritten = atomic.LoadInt64(&r.bytesWritten) - written
+		result.BytesRead = atomic.LoadInt64(&r.bytesRead) - read
+		result.Echo, result.Lines = splitEcho(lines, cmd)
+		if err == nil {
+			result.Prompt = r.Prompt()
+		}
+		return result, err
+	}
+
+	if err := r.WriteContext(ctx, cmd); err != nil {
+		return finish(err)
+	}
+	err := r.readWithReconnect(ctx, &readOptions{
+		stopOnEndLine: true,
+		onOut: func(v []string) {
+			lines = append(lines, v...)
+			if o.onOut != nil {
+				o.onOut(v)
+			}
+		},
+		onIntercept: func(index int, out, input string) {
+			result.Interceptions = append(result.Interceptions, Interception{Index: index, Output: out, Input: input, Time: time.Now()})
+		},
+		interceptors: o.interceptors,
+	})
+	return finish(err)
+}
+
+// splitEcho 拆分命令回显和命令输出：第一个非空行以命令结尾时，认为是命令回显
+func splitEcho(lines []string, cmd string) (echo string, out []string) {
+	lines = misc.TrimEmptyLine(lines)
+	if cmd = strings.TrimSpace(cmd); cmd != "" && len(lines) != 0 && strings.HasSuffix(strings.TrimSpace(lines[0]), cmd) {
+		echo, lines = lines[0], lines[1:]
+	}
+	return echo, misc.TrimEmptyLine(lines)
+}
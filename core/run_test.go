@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadWriter_Run(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	var out []string
+	r, err := rw.Run(context.Background(), "echo hello", WithOnOut(func(lines []string) { out = append(out, lines...) }))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "echo hello", r.Command)
+		assert.Equal(t, "echo hello", r.Echo)
+		assert.Equal(t, []string{"hello"}, r.Lines)
+		assert.Equal(t, "dev$ ", r.Prompt)
+		assert.Empty(t, r.Interceptions)
+		assert.Equal(t, int64(len("echo hello\n")), r.BytesWritten)
+		assert.Equal(t, int64(len("echo hello\r\nhello\r\ndev$ ")), r.BytesRead)
+		assert.False(t, r.Start.After(r.End))
+		assert.Equal(t, r.End.Sub(r.Start), r.Duration())
+	}
+	assert.Equal(t, []string{"echo hello", "hello"}, out)
+
+	r, err = rw.Run(context.Background(), "confirm", WithInterceptors(
+		interceptor.LastLinePattern(`^Password:`, "123"),
+		interceptor.LastLinePattern(`Continue\? \[y/n\]:`, "y"),
+	))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "confirm", r.Echo)
+		assert.Contains(t, r.Lines, "answer: y")
+		if assert.Len(t, r.Interceptions, 1) {
+			assert.Equal(t, 1, r.Interceptions[0].Index)
+			assert.Equal(t, "y", r.Interceptions[0].Input)
+			assert.Contains(t, r.Interceptions[0].Output, "Continue? [y/n]:")
+		}
+		assert.Equal(t, int64(len("confirm\ny\n")), r.BytesWritten)
+	}
+
+	// 超时时返回已读取到的部分结果
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	r, err = rw.Run(ctx, "hang")
+	assert.True(t, IsTimeout(err))
+	if assert.NotNil(t, r) {
+		assert.Equal(t, []string{"working"}, r.Lines)
+		assert.Equal(t, "", r.Prompt)
+		assert.False(t, r.End.IsZero())
+	}
+}
+
+func TestSplitEcho(t *testing.T) {
+	echo, out := splitEcho([]string{"", "[root@localhost ~]# ls", "a", "b", ""}, "ls")
+	assert.Equal(t, "[root@localhost ~]# ls", echo)
+	assert.Equal(t, []string{"a", "b"}, out)
+
+	echo, out = splitEcho([]string{"a", "b"}, "ls")
+	assert.Equal(t, "", echo)
+	assert.Equal(t, []string{"a", "b"}, out)
+
+	echo, out = splitEcho(nil, "ls")
+	assert.Equal(t, "", echo)
+	assert.Empty(t, out)
+}
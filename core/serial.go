@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"sync"
+)
+
+// Serial 串行执行命令的队列，多个协程共享同一个会话（如工作池中每个设备共用一个会话）时使用
+//
+//	命令按调用的先后顺序依次执行，每个命令的输出只返回给对应的调用方；排队期间 ctx 超时或取消时，命令不会执行
+//	通过 Serial 执行命令时，不能同时直接调用 ReadWriter 的读写方法
+type Serial struct {
+	r       *ReadWriter
+	mu      sync.Mutex
+	busy    bool            // 是否有命令正在执行
+	waiters []chan struct{} // 排队等待执行的命令，轮到执行时关闭对应的 channel
+}
+
+// Serial 返回 ReadWriter 的串行执行队列，同一个 ReadWriter 多次调用返回同一个队列
+func (r *ReadWriter) Serial() *Serial {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.serial == nil {
+		r.serial = &Serial{r: r}
+	}
+	return r.serial
+}
+
+// Do 排队获取执行权后调用 f，f 返回前其他命令不会执行，f 中可以调用 ReadWriter 的任意读写方法
+func (s *Serial) Do(ctx context.Context, f func(r *ReadWriter) error) error {
+	if err := s.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.release()
+	return f(s.r)
+}
+
+// Run 排队执行命令，参考 ReadWriter.Run
+func (s *Serial) Run(ctx context.Context, cmd string, opts ...RunOption) (result *Result, err error) {
+	err = s.Do(ctx, func(r *ReadWriter) error {
+		result, err = r.Run(ctx, cmd, opts...)
+		return err
+	})
+	return result, err
+}
+
+// Exec 排队执行命令并获取退出码，参考 ReadWriter.ExecContext
+func (s *Serial) Exec(ctx context.Context, cmd string, interceptors ...interceptor.Interceptor) (result *ExecResult, err error) {
+	err = s.Do(ctx, func(r *ReadWriter) error {
+		result, err = r.ExecContext(ctx, cmd, interceptors...)
+		return err
+	})
+	return result, err
+}
+
+// acquire 按先后顺序获取执行权，排队期间 ctx 超时或取消时返回对应的错误
+func (s *Serial) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return ctxError(err)
+	}
+
+	s.mu.Lock()
+	if !s.busy {
+		s.busy = true
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.waiters = append(s.waiters, ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	for i, v := range s.waiters {
+		if v == ch {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			s.mu.Unlock()
+			return ctxError(ctx.Err())
+		}
+	}
+	s.mu.Unlock()
+	// 取消的同时已经获得了执行权，交给下一个命令
+	s.release()
+	return ctxError(ctx.Err())
+}
+
+func (s *Serial) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiters) == 0 {
+		s.busy = false
+		return
+	}
+	ch := s.waiters[0]
+	s.waiters = s.waiters[1:]
+	close(ch)
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSerial_Run(t *testing.T) {
+	for _, cfg := range []Config{{ReadConfirmWait: 20 * time.Millisecond}, {ReadConfirmWait: 20 * time.Millisecond, LazyOutInterval: 50 * time.Millisecond, LazyOutSize: 16}} {
+		rw := newTestPosixShell(t, cfg)
+		assert.Same(t, rw.Serial(), rw.Serial())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+
+				cmd := fmt.Sprintf("echo %d", i)
+				var out []string
+				res, err := rw.Serial().Run(ctx, cmd, WithOnOut(func(lines []string) { out = append(out, lines...) }))
+				if assert.NoError(t, err) {
+					assert.Equal(t, []string{fmt.Sprint(i)}, res.Lines)
+					assert.Equal(t, []string{cmd, fmt.Sprint(i)}, out)
+					_ = rw.Prompt()
+				}
+
+				exec, err := rw.Serial().Exec(ctx, "false")
+				if assert.NoError(t, err) {
+					assert.Equal(t, 1, exec.ExitCode)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+func TestSerial_Order(t *testing.T) {
+	rw := newTestPosixShell(t)
+	s := rw.Serial()
+
+	var mu sync.Mutex
+	var order []int
+	release := make(chan struct{})
+	first := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), func(r *ReadWriter) error {
+			close(first)
+			<-release
+			return nil
+		})
+	}()
+	<-first
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = s.Do(context.Background(), func(r *ReadWriter) error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, i)
+				return nil
+			})
+		}(i)
+		// 确保按顺序排队
+		assert.Eventually(t, func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.waiters) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+}
+
+func TestSerial_CancelQueued(t *testing.T) {
+	rw := newTestPosixShell(t)
+	s := rw.Serial()
+
+	release := make(chan struct{})
+	first := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), func(r *ReadWriter) error {
+			close(first)
+			<-release
+			return nil
+		})
+	}()
+	<-first
+
+	// 排队期间超时，命令不会执行
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := s.Run(ctx, "echo skipped")
+	assert.True(t, IsTimeout(err), err)
+	s.mu.Lock()
+	assert.Len(t, s.waiters, 0)
+	s.mu.Unlock()
+
+	close(release)
+	res, err := s.Run(context.Background(), "echo after")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"after"}, res.Lines)
+	}
+}
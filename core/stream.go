@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"time"
+)
+
+// LineSource 输出的来源
+type LineSource string
+
+const (
+	Stdout LineSource = "stdout"
+	Stderr LineSource = "stderr"
+)
+
+// Line 流式读取到的一行输出
+type Line struct {
+	Text   string
+	Time   time.Time  // 读取到该行的时间，精度取决于 Config.ReadConfirmWait
+	Source LineSource // 来源：stdout、stderr
+}
+
+// StreamPolicy 缓冲区已满（消费者来不及处理）时的处理策略
+type StreamPolicy int
+
+const (
+	StreamBlock      StreamPolicy = iota // 等待消费者读取，不丢弃任何行，默认值；等待期间暂停读取输出（背压），ctx 结束时丢弃
+	StreamDropNewest                     // 丢弃新读取到的行
+	StreamDropOldest                     // 丢弃缓冲区中最旧的行
+)
+
+type streamOptions struct {
+	buffer       int
+	policy       StreamPolicy
+	onDrop       func(line Line)
+	interceptors []interceptor.Interceptor
+}
+
+type StreamOption func(o *streamOptions)
+
+// WithStreamBuffer 缓冲区大小（行数），默认值 100
+func WithStreamBuffer(size int) StreamOption {
+	return func(o *streamOptions) {
+		o.buffer = size
+	}
+}
+
+// WithStreamPolicy 缓冲区已满时的处理策略，默认值 StreamBlock
+func WithStreamPolicy(policy StreamPolicy) StreamOption {
+	return func(o *streamOptions) {
+		o.policy = policy
+	}
+}
+
+// WithStreamOnDrop 丢弃行时的回调，可用于统计丢弃的行数
+func WithStreamOnDrop(onDrop func(line Line)) StreamOption {
+	return func(o *streamOptions) {
+		o.onDrop = onDrop
+	}
+}
+
+// WithStreamInterceptors 指定拦截器
+func WithStreamInterceptors(interceptors ...interceptor.Interceptor) StreamOption {
+	return func(o *streamOptions) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// Stream 执行命令，通过 channel 逐行返回输出（包括命令回显），读取到命令行提示符后结束
+//
+//	输出读取完毕后关闭 lines，然后向 errs 发送一次读取结果（成功时为 nil）并关闭 errs，调用方应先读取 lines 直到关闭，再读取 errs
+//	lines 最多缓存 WithStreamBuffer 指定的行数，缓冲区已满时按 StreamPolicy 处理：StreamBlock 时读取协程暂停读取输出，直到消费者读取或者 ctx 结束
+//	使用 StreamBlock 策略时，调用方不再读取 lines 的话需要取消 ctx，否则读取协程会一直阻塞
+//	Stream 结束前不能调用 ReadWriter 的其他读写方法
+func (r *ReadWriter) Stream(ctx context.Context, cmd string, opts ...StreamOption) (<-chan Line, <-chan error) {
+	o := streamOptions{buffer: 100}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.buffer <= 0 {
+		o.buffer = 1
+	}
+
+	lines, errs := make(chan Line, o.buffer), make(chan error, 1)
+	go func() {
+		err := r.WriteContext(ctx, cmd)
+		if err == nil {
+			emit := func(source LineSource) func(v []string) {
+				return func(v []string) {
+					now := time.Now()
+					for _, s := range v {
+						o.emit(ctx, lines, Line{Text: s, Time: now, Source: source})
+					}
+				}
+			}
+			err = r.readWithReconnect(ctx, &readOptions{
+				stopOnEndLine: true,
+				onOut:         emit(Stdout),
+				onErrOut:      emit(Stderr),
+				interceptors:  o.interceptors,
+			})
+		}
+		close(lines)
+		errs <- err
+		close(errs)
+	}()
+	return lines, errs
+}
+
+func (o *streamOptions) emit(ctx context.Context, ch chan Line, line Line) {
+	switch o.policy {
+	case StreamDropNewest:
+		select {
+		case ch <- line:
+		default:
+			o.drop(line)
+		}
+
+	case StreamDropOldest:
+		for {
+			select {
+			case ch <- line:
+				return
+			default:
+			}
+			select {
+			case old := <-ch:
+				o.drop(old)
+			default:
+			}
+		}
+
+	default:
+		// 缓冲区未满时优先写入，ctx 结束之前读取到的行不丢弃
+		select {
+		case ch <- line:
+			return
+		default:
+		}
+		select {
+		case ch <- line:
+		case <-ctx.Done():
+			o.drop(line)
+		}
+	}
+}
+
+func (o *streamOptions) drop(line Line) {
+	if o.onDrop != nil {
+		o.onDrop(line)
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReadWriter_Stream(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	// 消费者处理较慢时，等待消费者读取，不丢弃任何行
+	lines, errs := rw.Stream(context.Background(), "ping", WithStreamBuffer(1))
+	var texts []string
+	for line := range lines {
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, Stdout, line.Source)
+		assert.False(t, line.Time.IsZero())
+		texts = append(texts, line.Text)
+	}
+	assert.NoError(t, <-errs)
+	assert.Equal(t, "ping", texts[0])
+	assert.Len(t, texts, 11)
+}
+
+func TestReadWriter_StreamDrop(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	// 读取结束前不消费，丢弃新读取到的行
+	var dropped []string
+	lines, errs := rw.Stream(context.Background(), "ping", WithStreamBuffer(2), WithStreamPolicy(StreamDropNewest), WithStreamOnDrop(func(line Line) {
+		dropped = append(dropped, line.Text)
+	}))
+	assert.NoError(t, <-errs)
+	var texts []string
+	for line := range lines {
+		texts = append(texts, line.Text)
+	}
+	assert.Equal(t, []string{"ping", "reply"}, texts)
+	assert.Len(t, dropped, 9)
+
+	// 丢弃最旧的行
+	dropped = nil
+	lines, errs = rw.Stream(context.Background(), "echo last", WithStreamBuffer(1), WithStreamPolicy(StreamDropOldest), WithStreamOnDrop(func(line Line) {
+		dropped = append(dropped, line.Text)
+	}))
+	assert.NoError(t, <-errs)
+	texts = nil
+	for line := range lines {
+		texts = append(texts, line.Text)
+	}
+	assert.Equal(t, []string{"last"}, texts)
+	assert.Equal(t, []string{"echo last"}, dropped)
+}
+
+func TestReadWriter_StreamSlowConsumer(t *testing.T) {
+	outR, outW := io.Pipe()
+	defer outW.Close()
+	rw := New(io.Discard, outR, nil, Config{ReadConfirmWait: 10 * time.Millisecond})
+	defer rw.Stop()
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			_, _ = outW.Write([]byte("line\n"))
+		}
+		_, _ = outW.Write([]byte("dev$ "))
+	}()
+
+	// 消费者不读取时，缓冲区已满后暂停读取，缓存的行数不超过缓冲区大小
+	lines, errs := rw.Stream(context.Background(), "cmd", WithStreamBuffer(2))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 2, len(lines))
+	select {
+	case err := <-errs:
+		t.Fatalf("stream should wait for the consumer: %v", err)
+	default:
+	}
+
+	var texts []string
+	for line := range lines {
+		texts = append(texts, line.Text)
+	}
+	assert.NoError(t, <-errs)
+	assert.Equal(t, []string{"line", "line", "line", "line", "line"}, texts)
+}
+
+func TestReadWriter_StreamCancel(t *testing.T) {
+	rw := newTestPosixShell(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, errs := rw.Stream(ctx, "hang")
+	assert.Equal(t, "hang", (<-lines).Text)
+	cancel()
+	for range lines {
+	}
+	assert.True(t, IsCanceled(<-errs))
+}
+
+func TestReadWriter_StreamLazyOutCancel(t *testing.T) {
+	rw := newTestPosixShell(t, Config{ReadConfirmWait: 10 * time.Millisecond, LazyOutInterval: 300 * time.Millisecond})
+
+	// 取消时 LazyOut 中缓存的行在关闭 lines 之前输出，之后的定时输出不再调用本次读取的回调
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, errs := rw.Stream(ctx, "hang")
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	var texts []string
+	for line := range lines {
+		texts = append(texts, line.Text)
+	}
+	assert.True(t, IsCanceled(<-errs))
+	assert.Equal(t, []string{"hang", "working"}, texts)
+	time.Sleep(400 * time.Millisecond)
+}
+
+func TestReadWriter_StreamStderr(t *testing.T) {
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+	defer outW.Close()
+	defer errW.Close()
+	rw := New(io.Discard, outR, errR, Config{})
+	defer rw.Stop()
+
+	go func() {
+		_, _ = outW.Write([]byte("out\n"))
+		_, _ = errW.Write([]byte("err1\nerr2\n"))
+		time.Sleep(200 * time.Millisecond)
+		_, _ = outW.Write([]byte("dev$ "))
+	}()
+	lines, errs := rw.Stream(context.Background(), "cmd")
+	var stdout, stderr []string
+	for line := range lines {
+		if line.Source == Stderr {
+			stderr = append(stderr, line.Text)
+		} else {
+			stdout = append(stdout, line.Text)
+		}
+	}
+	assert.NoError(t, <-errs)
+	assert.Equal(t, []string{"out"}, stdout)
+	assert.Equal(t, []string{"err1", "err2"}, stderr)
+}
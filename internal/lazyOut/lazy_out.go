@@ -1,7 +1,6 @@
 package lazyOut
 
 import (
-	"github.com/3th1nk/easygo/util/timeUtil"
 	"sync"
 	"time"
 )
@@ -16,19 +15,28 @@ type LazyOut struct {
 	lineSize int
 	lines    []string
 	mu       sync.Mutex
-	ticker   *timeUtil.Ticker
+	outMu    sync.Mutex // 保证 onOut 依次调用，Out 返回时没有正在进行的 onOut
+	ticker   *time.Ticker
+	stop     chan struct{}
 	nextTick time.Time
 	interval time.Duration
 }
 
 func (l *LazyOut) Stop() {
+	l.mu.Lock()
 	if l.ticker != nil {
-		l.ticker.Stop(0)
+		l.ticker.Stop()
+		close(l.stop)
+		l.ticker = nil
 	}
+	l.mu.Unlock()
 	l.Out()
 }
 
 func (l *LazyOut) Out() {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+
 	l.mu.Lock()
 	onOut := l.onOut
 	lines := l.lines
@@ -40,6 +48,22 @@ func (l *LazyOut) Out() {
 	}
 }
 
+// Detach 输出缓冲区中的内容并清除 onOut，之后的 Add 和定时输出都被忽略，直到再次调用 SetOut
+func (l *LazyOut) Detach() {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+
+	l.mu.Lock()
+	onOut := l.onOut
+	lines := l.lines
+	l.onOut, l.lines, l.lineSize = nil, nil, 0
+	l.mu.Unlock()
+
+	if onOut != nil && len(lines) != 0 {
+		onOut(lines)
+	}
+}
+
 func (l *LazyOut) SetOut(f func(lines []string)) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -51,7 +75,19 @@ func (l *LazyOut) SetOut(f func(lines []string)) {
 		if d < 10*time.Millisecond {
 			d = 10 * time.Millisecond
 		}
-		l.ticker = timeUtil.NewTicker(d, d, func(t time.Time) {
+		l.ticker, l.stop = time.NewTicker(d), make(chan struct{})
+		go l.tick(l.ticker, l.stop)
+		l.nextTick = time.Now().Add(l.interval)
+	}
+}
+
+func (l *LazyOut) tick(ticker *time.Ticker, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case t := <-ticker.C:
+			l.outMu.Lock()
 			var lines []string
 			l.mu.Lock()
 			onOut := l.onOut
@@ -64,18 +100,21 @@ func (l *LazyOut) SetOut(f func(lines []string)) {
 			if onOut != nil && lines != nil {
 				onOut(lines)
 			}
-		})
-		l.nextTick = time.Now().Add(l.interval)
+			l.outMu.Unlock()
+		}
 	}
 }
 
 func (l *LazyOut) Add(lines []string) {
-	if l.onOut == nil {
-		return
-	}
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
 
 	l.mu.Lock()
 	onOut := l.onOut
+	if onOut == nil {
+		l.mu.Unlock()
+		return
+	}
 	var outLines []string
 	l.lines = append(l.lines, lines...)
 	for _, s := range lines {
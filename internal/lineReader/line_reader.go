@@ -1,11 +1,12 @@
 package lineReader
 
 import (
-	"bytes"
-	"github.com/3th1nk/easygo/charset"
 	"github.com/3th1nk/easygo/util"
 	"github.com/3th1nk/easyshell/internal/misc"
+	"github.com/3th1nk/easyshell/pkg/charset"
 	"github.com/3th1nk/easyshell/pkg/filter"
+	"github.com/3th1nk/easyshell/pkg/style"
+	"golang.org/x/text/transform"
 	"io"
 	"strings"
 	"sync"
@@ -17,28 +18,51 @@ func New(r io.Reader, opts ...Option) *LineReader {
 	}
 
 	obj := &LineReader{
-		r:      r,
-		filter: filter.NewDefaultFilter(),
-		lines:  make([]string, 0, 4),
+		r:     r,
+		lines: make([]string, 0, 4),
 	}
 	for _, opt := range opts {
 		opt(obj)
 	}
+	if misc.IsNil(obj.filter) {
+		// 需要渲染带样式的输出时，默认过滤器保留 SGR 控制序列
+		opt := filter.DefaultOptions
+		opt.KeepSGR = obj.renderer != nil
+		obj.filter = filter.NewDefaultFilter(opt)
+	}
+	if obj.decoder == nil {
+		// 未指定解码器时自动识别编码，同一个会话的多个 LineReader 需要共享识别结果时由调用方传入同一个 charset.Auto()
+		obj.decoder = charset.Auto()()
+	}
 	go obj.read()
 	return obj
 }
 
 type Option func(*LineReader)
 
-func WithDecoder(decoder func(b []byte) ([]byte, error)) Option {
+// WithDecoder 读取到数据后先解码为 UTF8，再交给过滤器处理，被拆分到多次读取中的多字节字符保留在解码器中
+func WithDecoder(factory charset.Factory) Option {
 	return func(reader *LineReader) {
-		reader.decoder = decoder
+		if factory != nil {
+			reader.decoder = factory()
+		}
 	}
 }
 
-func WithFilter(filter filter.IFilter) Option {
+func WithFilter(factory filter.Factory) Option {
 	return func(reader *LineReader) {
-		reader.filter = filter
+		if factory != nil {
+			reader.filter = factory()
+		}
+	}
+}
+
+// WithRenderer 解析输出中的 SGR 控制序列（颜色、字体），使用 renderer 渲染每一行（如转换为 HTML），渲染结果通过 PopRenderedLines 获取
+//
+//	PopLines 返回的仍然是纯文本，用于匹配提示符、拦截器等；使用自定义过滤器时，过滤器需要保留 SGR 控制序列（如 filter.ANSIKeepSGR）
+func WithRenderer(renderer style.Renderer) Option {
+	return func(reader *LineReader) {
+		reader.renderer = renderer
 	}
 }
 
@@ -49,74 +73,73 @@ func WithRawOut(w io.Writer) Option {
 }
 
 type LineReader struct {
-	r               io.Reader                      //
-	rawOut          io.Writer                      // 原始数据输出
-	filter          filter.IFilter                 // 字符过滤器
-	decoder         func(b []byte) ([]byte, error) // 二进制解码函数
-	lines           []string                       // 缓冲区中已经读取到的行
-	remaining       string                         // 缓冲区中最后一个换行符后面的部分
-	remainingOffset int                            // 缓冲区中最后一个换行符后面的部分的长度（在缓冲区中的原始长度）
-	mu              sync.Mutex                     //
-	err             error                          //
+	r                 io.Reader             //
+	rawOut            io.Writer             // 原始数据输出
+	filter            filter.IFilter        // 字符过滤器
+	decoder           transform.Transformer // 解码器
+	undecoded         []byte                // 末尾不完整、等待后续数据的多字节字符
+	renderer          style.Renderer        // 带样式的输出的渲染器
+	parser            style.Parser          // SGR 解析器，保存已读取到的行结束时的样式
+	remainingParser   style.Parser          // 解析最后一行之后的 SGR 解析器，丢弃最后一行时使用
+	lines             []string              // 缓冲区中已经读取到的行
+	remaining         string                // 缓冲区中最后一个换行符后面的部分
+	rendered          []string              // 渲染后的 lines，仅在 renderer 不为 nil 时有效
+	renderedRemaining string                // 渲染后的 remaining，仅在 renderer 不为 nil 时有效
+	mu                sync.Mutex            //
+	err               error                 //
 }
 
 func (lr *LineReader) read() {
-	// 缓冲区、缓冲区写入位置
-	// ！！！Read()最多读取一个缓冲区大小的内容，有可能读取不完整，导致字符过滤时可能出现问题
-	//	这里基于经验设置大小为4096，只是尽量降低发生概率，无法完全避免
-	buf, offset := make([]byte, 4096), 0
+	// 解码器、过滤器在内部保留未结束的内容，每次只传入新读取到的内容
+	buf := make([]byte, 4096)
 	for {
-		n, err := lr.r.Read(buf[offset:])
+		n, err := lr.r.Read(buf)
 		if err != nil {
+			lr.mu.Lock()
+			// 输出已经结束，末尾不完整的多字节字符也需要输出
+			if s := lr.decode(nil, true); len(s) != 0 {
+				lr.write(s)
+			}
 			lr.err = err
+			lr.mu.Unlock()
 			return
 		}
-		size := offset + n
-		if err = lr.doRawOut(buf[offset:size]); err != nil {
+		if err = lr.doRawOut(buf[:n]); err != nil {
 			util.PrintErrln("write raw out failed: %s", err)
 		}
 
 		lr.mu.Lock()
+		lr.write(lr.decode(buf[:n], false))
+		lr.mu.Unlock()
+	}
+}
 
-		// 获取有效的缓冲区内容（并移除掉已经丢弃的 remaining）
-		var realBuf []byte
-		if lr.remaining == "" && lr.remainingOffset != 0 {
-			// 如果 remaining 为空、但 remainingOffset 不为 0 ，表示 remaining 在 popLine 函数中已经被丢弃了
-			realBuf = lr.doFilter(buf[lr.remainingOffset:size])
-			lr.remainingOffset = 0
-		} else {
-			realBuf = lr.doFilter(buf[:size])
-		}
-
-		// 从缓冲区最后一个换行符的位置，将缓冲区拆分为两部分
-		//   如果有换行符，则缓冲区中只留下最后一个换行符后面的内容；
-		//   如果没有换行符，缓冲区保留（同时要判断缓冲区自动扩容）。
-		if i := bytes.LastIndexByte(realBuf, '\n'); i >= 0 {
-			if linesStr := lr.decode(realBuf[:i]); linesStr != "" {
-				arr := strings.Split(linesStr, "\n")
-				lr.lines = append(lr.lines, arr...)
+// write 将解码后的内容写入过滤器，更新缓冲区中的行
+func (lr *LineReader) write(p []byte) {
+	if out := lr.filter.Write(p); len(out) != 0 {
+		lines := strings.Split(string(out[:len(out)-1]), "\n")
+		if lr.renderer != nil {
+			for _, line := range lines {
+				styled := lr.parser.Parse(line)
+				lr.lines = append(lr.lines, styled.Text())
+				lr.rendered = append(lr.rendered, lr.renderer.Render(styled))
 			}
-
-			// remaining
-			lr.remaining = lr.decode(realBuf[i+1:])
-			copy(buf, realBuf[i+1:])
-			offset = len(realBuf[i+1:])
-
 		} else {
-			// 自动扩容
-			if size == len(buf) {
-				buf = make([]byte, size*2)
-			}
-
-			// remaining
-			lr.remaining = lr.decode(realBuf)
-			copy(buf, realBuf)
-			offset = len(realBuf)
+			lr.lines = append(lr.lines, lines...)
 		}
-		lr.remainingOffset = offset
+	}
+	lr.setRemaining(string(lr.filter.Pending()))
+}
 
-		lr.mu.Unlock()
+func (lr *LineReader) setRemaining(s string) {
+	if lr.renderer == nil {
+		lr.remaining = s
+		return
 	}
+	// 最后一行可能还会变化，使用副本解析
+	lr.remainingParser = lr.parser
+	styled := lr.remainingParser.Parse(s)
+	lr.remaining, lr.renderedRemaining = styled.Text(), lr.renderer.Render(styled)
 }
 
 func (lr *LineReader) doRawOut(s []byte) error {
@@ -128,46 +151,100 @@ func (lr *LineReader) doRawOut(s []byte) error {
 	return nil
 }
 
-func (lr *LineReader) doFilter(s []byte) []byte {
-	if !misc.IsNil(lr.filter) {
-		return lr.filter.Do(s)
+// decode 将 p 解码为 UTF8，末尾不完整的多字节字符保留到下次解码，atEOF 为 true 时输出保留的内容
+func (lr *LineReader) decode(p []byte, atEOF bool) []byte {
+	src := p
+	if len(lr.undecoded) != 0 {
+		src = append(lr.undecoded, p...)
+		lr.undecoded = nil
 	}
-	return s
-}
-
-func (lr *LineReader) decode(s []byte) string {
-	if len(s) == 0 {
-		return ""
+	if len(src) == 0 && !atEOF {
+		return nil
 	}
 
-	if lr.decoder != nil {
-		if s2, err := lr.decoder(s); err == nil {
-			return string(s2)
+	dst := make([]byte, len(src)*2+16)
+	var out []byte
+	for {
+		nDst, nSrc, err := lr.decoder.Transform(dst, src, atEOF)
+		out = append(out, dst[:nDst]...)
+		src = src[nSrc:]
+		switch err {
+		case nil:
+			return out
+		case transform.ErrShortDst:
+			if nDst == 0 {
+				dst = make([]byte, len(dst)*2)
+			}
+		case transform.ErrShortSrc:
+			lr.undecoded = append([]byte(nil), src...)
+			return out
+		default:
+			// 无法解码的内容原样输出
+			lr.decoder.Reset()
+			return append(out, src...)
 		}
-		return string(s)
 	}
-	// 如果没有指定自定义的解码方法，则默认尝试转换UTF8
-	return charset.ToUTF8(string(s))
+}
+
+// Err 返回读取时发生的错误（如 EOF），不为 nil 时表示已经读取结束
+func (lr *LineReader) Err() error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.err
+}
+
+// DropRemaining 缓冲区中只有最后一行（没有换行符）、且内容为 s 时丢弃该行
+func (lr *LineReader) DropRemaining(s string) bool {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if s != "" && len(lr.lines) == 0 && lr.remaining == s {
+		lr.dropRemaining()
+		return true
+	}
+	return false
 }
 
 func (lr *LineReader) PopLines(f func(lines []string, remaining string) (dropRemaining bool)) (popped int, err error) {
+	return lr.PopRenderedLines(func(lines []string, remaining string, _ []string, _ string) bool {
+		return f(lines, remaining)
+	})
+}
+
+// PopRenderedLines 与 PopLines 相同，同时返回渲染后的内容（rendered、renderedRemaining 与 lines、remaining 一一对应），未指定渲染器时与 lines、remaining 相同
+func (lr *LineReader) PopRenderedLines(f func(lines []string, remaining string, rendered []string, renderedRemaining string) (dropRemaining bool)) (popped int, err error) {
 	lr.mu.Lock()
 	defer lr.mu.Unlock()
 
 	if len(lr.lines) == 0 && lr.remaining == "" {
-		return 0, nil
+		// 缓冲区已读完，返回读取时发生的错误（如连接断开时的 EOF）
+		return 0, lr.err
 	}
 
+	rendered, renderedRemaining := lr.lines, lr.remaining
+	if lr.renderer != nil {
+		rendered, renderedRemaining = lr.rendered, lr.renderedRemaining
+	}
 	var droppedRemaining int
-	if f(lr.lines, lr.remaining) {
-		lr.remaining = ""
+	if f(lr.lines, lr.remaining, rendered, renderedRemaining) {
+		lr.dropRemaining()
 		droppedRemaining = 1
 	}
 
 	droppedLines := len(lr.lines)
 	if droppedLines > 0 {
 		lr.lines = lr.lines[:0]
+		lr.rendered = lr.rendered[:0]
 	}
 
 	return droppedLines + droppedRemaining, lr.err
 }
+
+// dropRemaining 丢弃最后一行，同时丢弃过滤器中保留的内容，避免后续的内容中再次出现
+func (lr *LineReader) dropRemaining() {
+	if lr.remaining == "" {
+		return
+	}
+	lr.remaining, lr.renderedRemaining = "", ""
+	lr.parser = lr.remainingParser
+	lr.filter.Flush()
+}
@@ -0,0 +1,114 @@
+package lineReader
+
+import (
+	"github.com/3th1nk/easyshell/pkg/charset"
+	"github.com/3th1nk/easyshell/pkg/filter"
+	"github.com/3th1nk/easyshell/pkg/style"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"io"
+	"testing"
+	"time"
+)
+
+// chunkReader 每次 Read 返回一个块，用于模拟被拆分到多次读取中的输出
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if r.chunks[0] = r.chunks[0][n:]; r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+type result struct {
+	lines, rendered              []string
+	remaining, renderedRemaining string
+}
+
+// readAll 等待读取结束，返回缓冲区中的全部内容
+func readAll(t *testing.T, lr *LineReader) (res result) {
+	deadline := time.Now().Add(5 * time.Second)
+	for lr.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("read timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_, err := lr.PopRenderedLines(func(lines []string, remaining string, rendered []string, renderedRemaining string) bool {
+		res.lines, res.rendered = append(res.lines, lines...), append(res.rendered, rendered...)
+		res.remaining, res.renderedRemaining = remaining, renderedRemaining
+		return true
+	})
+	assert.Equal(t, io.EOF, err)
+	return res
+}
+
+func TestLineReader_Screen(t *testing.T) {
+	// 进度条通过回车符重绘，只输出最终显示的内容
+	lr := New(&chunkReader{chunks: []string{"Copying...\r\n", "  0%", "\r 50%", "\r100%\r\n", "\x1b[31mdo", "ne\x1b[0m\r\n", "sw1# "}},
+		WithFilter(func() filter.IFilter { return filter.NewScreen() }))
+	res := readAll(t, lr)
+	assert.Equal(t, []string{"Copying...", "100%", "done"}, res.lines)
+	assert.Equal(t, "sw1# ", res.remaining)
+}
+
+func TestLineReader_Renderer(t *testing.T) {
+	// SGR 控制序列被拆分到多次读取中，输出为渲染后的 HTML，lines 仍然是纯文本
+	lr := New(&chunkReader{chunks: []string{
+		"\x1b[3", "1mERROR\x1b[0m: disk <full>\r\n\x1b[38;2;0;128;255mINFO\x1b", "[0m: ok\r\n",
+		"\x1b[1;32msw1#\x1b[0m ",
+	}}, WithRenderer(style.HTML()))
+	res := readAll(t, lr)
+	assert.Equal(t, []string{"ERROR: disk <full>", "INFO: ok"}, res.lines)
+	assert.Equal(t, []string{
+		`<span style="color:#cd0000">ERROR</span>: disk &lt;full&gt;`,
+		`<span style="color:#0080ff">INFO</span>: ok`,
+	}, res.rendered)
+	assert.Equal(t, "sw1# ", res.remaining)
+	assert.Equal(t, `<span style="color:#00cd00;font-weight:bold">sw1#</span> `, res.renderedRemaining)
+
+	// 未指定渲染器时，渲染结果与纯文本相同
+	lr = New(&chunkReader{chunks: []string{"\x1b[31mred\x1b", "[0m\r\nsw1# "}})
+	res = readAll(t, lr)
+	assert.Equal(t, []string{"red"}, res.lines)
+	assert.Equal(t, res.lines, res.rendered)
+	assert.Equal(t, "sw1# ", res.renderedRemaining)
+}
+
+func TestLineReader_Charset(t *testing.T) {
+	// GBK 编码的输出，多字节字符被拆分到两次读取中
+	chunks := func() []string {
+		return []string{"\xbd\xd3\xbf\xda\xd7", "\xb4\xcc\xac\xa3\xba\xc6\xf4\xd3\xc3\r\n", "\x1b[31m\xb4\xed", "\xce\xf3\x1b[0m\r\n", "sw1# "}
+	}
+	for _, factory := range []charset.Factory{nil, charset.Fixed(simplifiedchinese.GBK)} {
+		// 先解码再过滤，过滤器不会把 GBK 编码的内容当作无效的 UTF8 替换掉
+		res := readAll(t, New(&chunkReader{chunks: chunks()}, WithDecoder(factory)))
+		assert.Equal(t, []string{"接口状态：启用", "错误"}, res.lines)
+		assert.Equal(t, "sw1# ", res.remaining)
+	}
+
+	// 先解码再处理退格，两个退格删除一个汉字（GB18030 编码以及 UTF8）
+	for _, v := range []struct {
+		factory charset.Factory
+		chunks  []string
+	}{
+		{charset.Fixed(simplifiedchinese.GB18030), []string{"ab\xd6\xd0\xce", "\xc4\b\b\r\n", "sw1# "}},
+		{nil, []string{"ab中\xe6\x96", "\x87\b\b\r\n", "sw1# "}},
+	} {
+		res := readAll(t, New(&chunkReader{chunks: v.chunks}, WithDecoder(v.factory)))
+		assert.Equal(t, []string{"ab中"}, res.lines)
+		assert.Equal(t, "sw1# ", res.remaining)
+	}
+
+	// UTF8 的多字节字符被拆分到多次读取中
+	res := readAll(t, New(&chunkReader{chunks: []string{"\xe6", "\x8e\xa5\xe5\x8f", "\xa3\r\n\xe7\x8a\xb6"}}))
+	assert.Equal(t, []string{"接口"}, res.lines)
+	assert.Equal(t, "状", res.remaining)
+}
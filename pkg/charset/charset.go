@@ -0,0 +1,187 @@
+package charset
+
+import (
+	"bytes"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"sync"
+	"unicode/utf8"
+)
+
+// Factory 创建解码器（将输出转换为 UTF8），解码器是有状态的（保留被拆分到多次读取中的多字节字符），每个输出流都会创建单独的解码器
+type Factory func() transform.Transformer
+
+// Fixed 固定使用指定的编码
+func Fixed(enc encoding.Encoding) Factory {
+	return func() transform.Transformer {
+		return enc.NewDecoder()
+	}
+}
+
+// DefaultCandidates 自动识别编码时默认的候选编码
+var DefaultCandidates = []encoding.Encoding{unicode.UTF8, simplifiedchinese.GB18030}
+
+// Auto 自动识别编码，candidates 为按优先级排列的候选编码，默认为 DefaultCandidates
+//
+//	同一个 Factory 创建的解码器共享识别结果：任意一个解码器识别出编码后，其他解码器（如 stderr、重连后的输出流）也固定使用该编码，
+//	因此每个会话需要单独调用 Auto；未指定 Decoder 时，每个会话默认使用 Auto()
+func Auto(candidates ...encoding.Encoding) Factory {
+	if len(candidates) == 0 {
+		candidates = DefaultCandidates
+	}
+	s := &session{candidates: candidates}
+	return func() transform.Transformer {
+		return &Detector{s: s}
+	}
+}
+
+// MinDetectBytes 多个候选编码都能解码时，需要的非 ASCII 字节数，达到后选择优先级最高的编码
+var MinDetectBytes = 8
+
+// maxSample 识别编码时最多保留的数据
+const maxSample = 4096
+
+type session struct {
+	candidates []encoding.Encoding
+	mu         sync.Mutex
+	enc        encoding.Encoding // 识别出的编码
+}
+
+func (s *session) get() encoding.Encoding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc
+}
+
+func (s *session) lock(enc encoding.Encoding) encoding.Encoding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enc == nil {
+		s.enc = enc
+	}
+	return s.enc
+}
+
+// Detector 自动识别编码的解码器
+//
+//	识别出编码之前：只有 ASCII 字符时原样输出；只有一个候选编码能够解码、或者非 ASCII 字节数达到 MinDetectBytes 时固定使用该编码；
+//	多个候选编码都能解码时暂时使用优先级最高的编码；所有候选编码都不能解码时原样输出
+type Detector struct {
+	s      *session
+	dec    transform.Transformer // 识别出编码后使用的解码器
+	sample []byte                // 识别出编码之前已经处理的数据
+}
+
+// NewDetector 创建一个单独的自动识别编码的解码器
+func NewDetector(candidates ...encoding.Encoding) *Detector {
+	return Auto(candidates...)().(*Detector)
+}
+
+// Encoding 返回识别出的编码，尚未识别出时返回 nil
+func (d *Detector) Encoding() encoding.Encoding {
+	return d.s.get()
+}
+
+func (d *Detector) Reset() {
+	if d.dec != nil {
+		d.dec.Reset()
+	}
+	d.sample = nil
+}
+
+func (d *Detector) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if d.dec == nil {
+		if enc := d.s.get(); enc != nil {
+			d.dec = enc.NewDecoder()
+		}
+	}
+	if d.dec != nil {
+		return d.dec.Transform(dst, src, atEOF)
+	}
+
+	enc, sure := d.detect(src, atEOF)
+	if sure {
+		d.dec, d.sample = d.s.lock(enc).NewDecoder(), nil
+		return d.dec.Transform(dst, src, atEOF)
+	}
+	if enc == nil {
+		// 只有 ASCII 字符或者无法解码，原样输出
+		nSrc = copy(dst, src)
+		if nSrc < len(src) {
+			err = transform.ErrShortDst
+		}
+		nDst = nSrc
+	} else {
+		nDst, nSrc, err = enc.NewDecoder().Transform(dst, src, atEOF)
+	}
+	if d.sample = append(d.sample, src[:nSrc]...); len(d.sample) > maxSample {
+		d.sample = d.sample[len(d.sample)-maxSample:]
+	}
+	return nDst, nSrc, err
+}
+
+// detect 根据已经处理的数据以及 src 识别编码，返回识别出的编码以及是否确定
+func (d *Detector) detect(src []byte, atEOF bool) (encoding.Encoding, bool) {
+	data := append(d.sample[:len(d.sample):len(d.sample)], src...)
+	nonASCII := 0
+	for _, c := range data {
+		if c >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+	if nonASCII == 0 {
+		return nil, false
+	}
+
+	var valid []encoding.Encoding
+	for _, enc := range d.s.candidates {
+		if canDecode(enc, data, atEOF) {
+			valid = append(valid, enc)
+		}
+	}
+	switch {
+	case len(valid) == 0:
+		// 之前的数据可能影响后续的识别，丢弃
+		d.sample = nil
+		return nil, false
+	case len(valid) == 1:
+		return valid[0], true
+	default:
+		return valid[0], nonASCII >= MinDetectBytes
+	}
+}
+
+// canDecode 是否能够使用 enc 解码 data（不包括末尾被拆分的多字节字符）
+func canDecode(enc encoding.Encoding, data []byte, atEOF bool) bool {
+	if enc == unicode.UTF8 {
+		// 输出中可能本来就有替换字符 U+FFFD，单独判断
+		if !atEOF {
+			data = trimIncompleteRune(data)
+		}
+		return utf8.Valid(data)
+	}
+
+	out := make([]byte, len(data)*3+utf8.UTFMax)
+	nDst, _, err := enc.NewDecoder().Transform(out, data, atEOF)
+	if err != nil && err != transform.ErrShortSrc {
+		return false
+	}
+	return !bytes.ContainsRune(out[:nDst], utf8.RuneError)
+}
+
+// trimIncompleteRune 删除末尾不完整的 UTF8 字符
+func trimIncompleteRune(s []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(s); i++ {
+		if c := s[len(s)-i]; c >= 0xC0 {
+			if !utf8.FullRune(s[len(s)-i:]) {
+				s = s[:len(s)-i]
+			}
+			break
+		} else if c < 0x80 {
+			break
+		}
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package charset
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"testing"
+)
+
+// decodeSplit 将 src 拆分为两次读取后解码
+func decodeSplit(t transform.Transformer, src []byte, at int) (string, error) {
+	var out []byte
+	var undecoded []byte
+	for i, p := range [][]byte{src[:at], src[at:]} {
+		p = append(undecoded, p...)
+		dst := make([]byte, len(p)*3+16)
+		nDst, nSrc, err := t.Transform(dst, p, i == 1)
+		if err != nil && err != transform.ErrShortSrc {
+			return "", err
+		}
+		out = append(out, dst[:nDst]...)
+		undecoded = append([]byte(nil), p[nSrc:]...)
+	}
+	return string(out), nil
+}
+
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	assert.NoError(t, err)
+	return b
+}
+
+func TestFixed_Split(t *testing.T) {
+	for _, v := range []struct {
+		enc encoding.Encoding
+		s   string
+	}{
+		{simplifiedchinese.GB18030, "接口 GigabitEthernet0/0/1 状态：启用€"},
+		{traditionalchinese.Big5, "介面狀態：啟用"},
+		{japanese.ShiftJIS, "インターフェース状態：有効"},
+		{unicode.UTF8, "接口状态：启用"},
+	} {
+		src := encode(t, v.enc, v.s)
+		for at := 0; at <= len(src); at++ {
+			out, err := decodeSplit(Fixed(v.enc)(), src, at)
+			assert.NoError(t, err)
+			assert.Equal(t, v.s, out, "split at %d", at)
+		}
+	}
+}
+
+func TestAuto(t *testing.T) {
+	// 只有 ASCII 字符时原样输出，不确定编码
+	d := NewDetector()
+	out, err := decodeSplit(d, []byte("display version\r\n"), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "display version\r\n", out)
+	assert.Nil(t, d.Encoding())
+
+	// GBK 编码的内容在任意位置拆分
+	s := "接口状态：启用"
+	src := encode(t, simplifiedchinese.GBK, s)
+	for at := 0; at <= len(src); at++ {
+		d = NewDetector()
+		out, err = decodeSplit(d, src, at)
+		assert.NoError(t, err)
+		assert.Equal(t, s, out, "split at %d", at)
+		assert.Equal(t, simplifiedchinese.GB18030, d.Encoding(), "split at %d", at)
+	}
+
+	// UTF8 编码的内容在任意位置拆分
+	src = []byte(s)
+	for at := 0; at <= len(src); at++ {
+		d = NewDetector()
+		out, err = decodeSplit(d, src, at)
+		assert.NoError(t, err)
+		assert.Equal(t, s, out, "split at %d", at)
+		assert.Equal(t, unicode.UTF8, d.Encoding(), "split at %d", at)
+	}
+}
+
+func TestAuto_Session(t *testing.T) {
+	// 同一个会话的解码器共享识别结果
+	f := Auto()
+	d1, d2 := f().(*Detector), f().(*Detector)
+	out, err := decodeSplit(d1, encode(t, simplifiedchinese.GBK, "中文"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "中文", out)
+	assert.Equal(t, simplifiedchinese.GB18030, d2.Encoding())
+
+	// 识别出编码后不再改变：后续输出中即使只有两个字节也按 GB18030 解码（按 UTF8 也可以解码为 "ڀ"）
+	out, err = decodeSplit(d2, []byte{0xDA, 0x80}, 1)
+	assert.NoError(t, err)
+	expected, err := simplifiedchinese.GB18030.NewDecoder().Bytes([]byte{0xDA, 0x80})
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), out)
+
+	// 不同会话之间互不影响
+	d3 := Auto()().(*Detector)
+	assert.Nil(t, d3.Encoding())
+	out, err = decodeSplit(d3, []byte("中文"), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "中文", out)
+	assert.Equal(t, unicode.UTF8, d3.Encoding())
+}
+
+func TestAuto_Undecidable(t *testing.T) {
+	// 多个候选编码都能解码、且非 ASCII 字节较少时暂时使用优先级最高的编码，不固定
+	d := NewDetector()
+	out, err := decodeSplit(d, []byte("é\r\n"), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "é\r\n", out)
+	assert.Nil(t, d.Encoding())
+
+	// 所有候选编码都不能解码时原样输出
+	d = NewDetector(unicode.UTF8)
+	out, err = decodeSplit(d, []byte{'a', 0xFF, 'b'}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\xffb", out)
+	assert.Nil(t, d.Encoding())
+}
@@ -0,0 +1,98 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Dialer 建立网络连接的接口，与 net.Dialer 兼容
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Func 将普通函数转换为 Dialer
+type Func func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f Func) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// Direct 直接建立连接
+func Direct() Dialer {
+	return &net.Dialer{}
+}
+
+// Dial 使用 d 建立连接，d 为空时直接建立连接；timeout 大于 0 时，建立连接(含代理握手)的总时间不超过 timeout
+func Dial(d Dialer, network, addr string, timeout time.Duration) (net.Conn, error) {
+	if d == nil {
+		d = Direct()
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// FromURL 根据代理地址创建 Dialer，支持的格式：
+//
+//	socks5://[user:password@]host:port
+//	http://[user:password@]host:port
+//
+// forward 为连接代理服务器时使用的 Dialer，为空时直接连接代理服务器
+func FromURL(proxyURL string, forward Dialer) (Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var user, password string
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		return SOCKS5(u.Host, user, password, forward), nil
+	case "http":
+		return HTTPConnect(u.Host, user, password, forward), nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+}
+
+// withConnDeadline 将 ctx 的截止时间应用于 conn 并执行 f，ctx 被取消时立即中断 f 中阻塞的读写，用于限制代理握手的时间
+func withConnDeadline(ctx context.Context, conn net.Conn, f func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	err := f()
+	close(stop)
+	wg.Wait()
+	_ = conn.SetDeadline(time.Time{})
+
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T, handle func(c net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				handle(c)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// echoServer 原样返回收到的数据
+func echoServer(t *testing.T) string {
+	return listen(t, func(c net.Conn) {
+		_, _ = io.Copy(c, c)
+	})
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		_ = a.Close()
+	}()
+	_, _ = io.Copy(b, a)
+	_ = b.Close()
+}
+
+// socks5Server SOCKS5 代理服务器的简易实现，user 不为空时要求用户名密码认证
+func socks5Server(t *testing.T, user, password string) string {
+	return listen(t, func(c net.Conn) {
+		buf := make([]byte, 262)
+		if _, err := io.ReadFull(c, buf[:2]); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(c, buf[:buf[1]]); err != nil {
+			return
+		}
+		if user == "" {
+			_, _ = c.Write([]byte{socks5Version, socks5AuthNone})
+		} else {
+			_, _ = c.Write([]byte{socks5Version, socks5AuthPassword})
+			_, _ = io.ReadFull(c, buf[:2])
+			u := make([]byte, buf[1])
+			_, _ = io.ReadFull(c, u)
+			_, _ = io.ReadFull(c, buf[:1])
+			p := make([]byte, buf[0])
+			_, _ = io.ReadFull(c, p)
+			if string(u) != user || string(p) != password {
+				_, _ = c.Write([]byte{0x01, 0x01})
+				return
+			}
+			_, _ = c.Write([]byte{0x01, 0x00})
+		}
+
+		if _, err := io.ReadFull(c, buf[:4]); err != nil {
+			return
+		}
+		var host string
+		switch buf[3] {
+		case socks5AtypIPv4:
+			_, _ = io.ReadFull(c, buf[:4])
+			host = net.IP(buf[:4]).String()
+		case socks5AtypDomain:
+			_, _ = io.ReadFull(c, buf[:1])
+			n := int(buf[0])
+			_, _ = io.ReadFull(c, buf[:n])
+			host = string(buf[:n])
+		}
+		_, _ = io.ReadFull(c, buf[:2])
+		port := int(buf[0])<<8 | int(buf[1])
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			_, _ = c.Write([]byte{socks5Version, 0x05, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		_, _ = c.Write([]byte{socks5Version, 0x00, 0x00, socks5AtypIPv4, 127, 0, 0, 1, 0, 0})
+		pipe(c, target)
+	})
+}
+
+// httpProxyServer HTTP CONNECT 代理服务器的简易实现，user 不为空时要求 Basic 认证
+func httpProxyServer(t *testing.T, user, password string) string {
+	return listen(t, func(c net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		if user != "" && req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+password)) {
+			_, _ = c.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			_, _ = c.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		_, _ = c.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(c, target)
+	})
+}
+
+func assertEcho(t *testing.T, d Dialer, addr string) {
+	conn, err := Dial(d, "tcp", addr, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	assert.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
+
+func TestSOCKS5(t *testing.T) {
+	echo := echoServer(t)
+
+	assertEcho(t, SOCKS5(socks5Server(t, "", ""), "", "", nil), echo)
+
+	proxyAddr := socks5Server(t, "user", "pass")
+	assertEcho(t, SOCKS5(proxyAddr, "user", "pass", nil), echo)
+
+	// 域名由代理服务器解析
+	_, port, _ := net.SplitHostPort(echo)
+	assertEcho(t, SOCKS5(proxyAddr, "user", "pass", nil), net.JoinHostPort("localhost", port))
+
+	_, err := Dial(SOCKS5(proxyAddr, "user", "wrong", nil), "tcp", echo, 5*time.Second)
+	assert.Error(t, err)
+
+	_, err = Dial(SOCKS5(proxyAddr, "", "", nil), "tcp", echo, 5*time.Second)
+	assert.Error(t, err)
+
+	// 目标地址无法连接
+	_, err = Dial(SOCKS5(proxyAddr, "user", "pass", nil), "tcp", "127.0.0.1:1", 5*time.Second)
+	assert.Error(t, err)
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := echoServer(t)
+
+	assertEcho(t, HTTPConnect(httpProxyServer(t, "", ""), "", "", nil), echo)
+
+	proxyAddr := httpProxyServer(t, "user", "pass")
+	assertEcho(t, HTTPConnect(proxyAddr, "user", "pass", nil), echo)
+
+	_, err := Dial(HTTPConnect(proxyAddr, "user", "wrong", nil), "tcp", echo, 5*time.Second)
+	assert.Error(t, err)
+}
+
+func TestFromURL(t *testing.T) {
+	echo := echoServer(t)
+
+	d, err := FromURL("socks5://user:pass@"+socks5Server(t, "user", "pass"), nil)
+	if assert.NoError(t, err) {
+		assertEcho(t, d, echo)
+	}
+
+	d, err = FromURL("http://user:pass@"+httpProxyServer(t, "user", "pass"), nil)
+	if assert.NoError(t, err) {
+		assertEcho(t, d, echo)
+	}
+
+	// 代理链：先连接 SOCKS5 代理，再通过它连接 HTTP 代理
+	socks := SOCKS5(socks5Server(t, "", ""), "", "", nil)
+	d, err = FromURL("http://"+httpProxyServer(t, "", ""), socks)
+	if assert.NoError(t, err) {
+		assertEcho(t, d, echo)
+	}
+
+	_, err = FromURL("ftp://127.0.0.1:21", nil)
+	assert.Error(t, err)
+}
+
+func TestDialTimeout(t *testing.T) {
+	// 代理服务器接受连接但不响应
+	silent := listen(t, func(c net.Conn) {
+		time.Sleep(3 * time.Second)
+	})
+
+	start := time.Now()
+	_, err := Dial(SOCKS5(silent, "", "", nil), "tcp", "127.0.0.1:22", 200*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestSOCKS5Handshake(t *testing.T) {
+	echo := echoServer(t)
+	proxyAddr := listen(t, func(c net.Conn) {
+		target, err := SOCKS5Handshake(c, net.Dial)
+		if err != nil {
+			return
+		}
+		pipe(c, target)
+	})
+
+	assertEcho(t, SOCKS5(proxyAddr, "", "", nil), echo)
+
+	_, port, _ := net.SplitHostPort(echo)
+	assertEcho(t, SOCKS5(proxyAddr, "", "", nil), net.JoinHostPort("localhost", port))
+
+	// 仅支持无认证方式时，客户端仍可以连接
+	assertEcho(t, SOCKS5(proxyAddr, "user", "pass", nil), echo)
+
+	_, err := Dial(SOCKS5(proxyAddr, "", "", nil), "tcp", "127.0.0.1:1", 5*time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// HTTPConnect 通过 HTTP 代理的 CONNECT 方法建立隧道连接，user 不为空时使用 Basic 认证
+func HTTPConnect(proxyAddr, user, password string, forward Dialer) Dialer {
+	if forward == nil {
+		forward = Direct()
+	}
+	return &httpConnectDialer{proxyAddr: proxyAddr, user: user, password: password, forward: forward}
+}
+
+type httpConnectDialer struct {
+	proxyAddr string
+	user      string
+	password  string
+	forward   Dialer
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("http proxy: unsupported network: %s", network)
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var br *bufio.Reader
+	if err = withConnDeadline(ctx, conn, func() (err error) {
+		br, err = d.connect(conn, addr)
+		return err
+	}); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("http proxy %s: %w", d.proxyAddr, err)
+	}
+
+	// 代理服务器可能在响应头之后紧接着返回了目标服务器的数据
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+func (d *httpConnectDialer) connect(conn net.Conn, addr string) (*bufio.Reader, error) {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if d.user != "" {
+		req += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(d.user+":"+d.password)) + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("connect %s: %s", addr, resp.Status)
+	}
+	return br, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
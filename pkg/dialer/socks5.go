@@ -0,0 +1,179 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+	socks5AuthNoAccept = 0xFF
+
+	socks5CmdConnect = 0x01
+
+	socks5AtypIPv4   = 0x01
+	socks5AtypDomain = 0x03
+	socks5AtypIPv6   = 0x04
+)
+
+var socks5Replies = []string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// SOCKS5 通过 SOCKS5 代理(RFC 1928)建立连接，user 不为空时使用用户名密码认证(RFC 1929)
+//
+//	目标地址中的域名由代理服务器解析
+func SOCKS5(proxyAddr, user, password string, forward Dialer) Dialer {
+	if forward == nil {
+		forward = Direct()
+	}
+	return &socks5Dialer{proxyAddr: proxyAddr, user: user, password: password, forward: forward}
+}
+
+type socks5Dialer struct {
+	proxyAddr string
+	user      string
+	password  string
+	forward   Dialer
+}
+
+func (d *socks5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: unsupported network: %s", network)
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err = withConnDeadline(ctx, conn, func() error { return d.connect(conn, addr) }); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("socks5 proxy %s: %w", d.proxyAddr, err)
+	}
+	return conn, nil
+}
+
+func (d *socks5Dialer) connect(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 0xFFFF {
+		return fmt.Errorf("invalid port: %s", portStr)
+	}
+
+	// 协商认证方式
+	methods := []byte{socks5AuthNone}
+	if d.user != "" {
+		methods = []byte{socks5AuthNone, socks5AuthPassword}
+	}
+	if _, err = conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	buf := make([]byte, 2)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socks5Version {
+		return fmt.Errorf("unexpected version: %d", buf[0])
+	}
+	switch buf[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if d.user == "" {
+			return fmt.Errorf("authentication required")
+		}
+		if err = d.authenticate(conn); err != nil {
+			return err
+		}
+	case socks5AuthNoAccept:
+		return fmt.Errorf("no acceptable authentication methods")
+	default:
+		return fmt.Errorf("unsupported authentication method: %d", buf[1])
+	}
+
+	// 发送连接请求
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long: %s", host)
+		}
+		req = append(req, socks5AtypDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AtypIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AtypIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+
+	// 读取响应: VER REP RSV ATYP BND.ADDR BND.PORT
+	buf = make([]byte, 4)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[1] != 0x00 {
+		if int(buf[1]) < len(socks5Replies) && socks5Replies[buf[1]] != "" {
+			return fmt.Errorf("connect %s: %s", addr, socks5Replies[buf[1]])
+		}
+		return fmt.Errorf("connect %s: unknown reply %d", addr, buf[1])
+	}
+	var skip int
+	switch buf[3] {
+	case socks5AtypIPv4:
+		skip = net.IPv4len
+	case socks5AtypIPv6:
+		skip = net.IPv6len
+	case socks5AtypDomain:
+		if _, err = io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+		skip = int(buf[0])
+	default:
+		return fmt.Errorf("unknown address type: %d", buf[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+func (d *socks5Dialer) authenticate(conn net.Conn) error {
+	if len(d.user) > 255 || len(d.password) > 255 {
+		return fmt.Errorf("user or password too long")
+	}
+	req := []byte{0x01, byte(len(d.user))}
+	req = append(req, d.user...)
+	req = append(req, byte(len(d.password)))
+	req = append(req, d.password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[1] != 0x00 {
+		return fmt.Errorf("authentication failed")
+	}
+	return nil
+}
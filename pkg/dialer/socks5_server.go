@@ -0,0 +1,94 @@
+package dialer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5Handshake 作为 SOCKS5 服务端(RFC 1928)完成握手，并通过 dial 连接客户端请求的目标地址
+//
+//	仅支持无认证方式和 CONNECT 命令，返回与目标地址之间的连接，调用方负责在两个连接之间转发数据
+func SOCKS5Handshake(conn net.Conn, dial func(network, addr string) (net.Conn, error)) (net.Conn, error) {
+	// 协商认证方式: VER NMETHODS METHODS
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	if buf[0] != socks5Version {
+		return nil, fmt.Errorf("socks5: unexpected version: %d", buf[0])
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+	method := byte(socks5AuthNoAccept)
+	for _, m := range methods {
+		if m == socks5AuthNone {
+			method = socks5AuthNone
+			break
+		}
+	}
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return nil, err
+	}
+	if method == socks5AuthNoAccept {
+		return nil, fmt.Errorf("socks5: no acceptable authentication methods")
+	}
+
+	// 读取请求: VER CMD RSV ATYP DST.ADDR DST.PORT
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	if buf[1] != socks5CmdConnect {
+		_ = socks5Reply(conn, 0x07)
+		return nil, fmt.Errorf("socks5: unsupported command: %d", buf[1])
+	}
+	var host string
+	switch buf[3] {
+	case socks5AtypIPv4, socks5AtypIPv6:
+		ip := make([]byte, net.IPv4len)
+		if buf[3] == socks5AtypIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = net.IP(ip).String()
+	case socks5AtypDomain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return nil, err
+		}
+		name := make([]byte, buf[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return nil, err
+		}
+		host = string(name)
+	default:
+		_ = socks5Reply(conn, 0x08)
+		return nil, fmt.Errorf("socks5: unknown address type: %d", buf[3])
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(buf[0])<<8|int(buf[1])))
+
+	target, err := dial("tcp", addr)
+	if err != nil {
+		_ = socks5Reply(conn, 0x05)
+		return nil, fmt.Errorf("socks5: connect %s: %w", addr, err)
+	}
+	if err = socks5Reply(conn, 0x00); err != nil {
+		_ = target.Close()
+		return nil, err
+	}
+	return target, nil
+}
+
+// socks5Reply 发送响应，BND.ADDR 和 BND.PORT 总是为 0.0.0.0:0
+func socks5Reply(conn net.Conn, rep byte) error {
+	_, err := conn.Write([]byte{socks5Version, rep, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
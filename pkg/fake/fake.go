@@ -0,0 +1,261 @@
+package fake
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config 模拟命令行的配置
+type Config struct {
+	// 连接建立后输出的欢迎信息
+	Banner string
+	// 输出欢迎信息之后、第一个命令行提示符之前执行的步骤，如登录、修改过期的密码，返回错误时停止运行
+	Before []Step
+	// 命令行提示符，默认值 "fake# "
+	Prompt string
+	// 不回显输入的命令
+	NoEcho bool
+	// 换行符，输出内容中的 \n 会被替换为该换行符，默认值 "\r\n"
+	LineEnd string
+	// 分页提示符，默认值 "  ---- More ----"
+	MorePrompt string
+	// 大于 0 时，将输出内容拆分为指定大小的块依次写入，用于模拟网络分包
+	ChunkSize int
+	// 写入各个块之间的时间间隔
+	ChunkDelay time.Duration
+	// 没有匹配的命令时执行的步骤，默认输出 "% Unknown command: <cmd>"
+	Unknown func(cmd string) []Step
+}
+
+// Transport 模拟的交互式命令行，可直接传给 core.New 使用，用于在没有真实设备的情况下测试自动化流程
+//
+//	写入的命令按注册顺序依次匹配 Handle、HandleRegex、HandleFunc 注册的规则，执行匹配的步骤（输出内容、分页、延迟等），然后输出命令行提示符
+//
+//	t := fake.New(fake.Config{Prompt: "sw1# "})
+//	t.Handle("show version", fake.Output("Version 1.0\n"))
+//	rw := core.New(t, t, nil, core.Config{})
+type Transport struct {
+	cfg     Config
+	inR     *io.PipeReader
+	inW     *io.PipeWriter
+	outR    *io.PipeReader
+	outW    *io.PipeWriter
+	in      *bufio.Reader
+	mu      sync.Mutex
+	prompt  string
+	rules   []rule
+	inputs  []string
+	once    sync.Once
+	closed  chan struct{}
+	stopped chan struct{}
+}
+
+type rule struct {
+	match func(cmd string) []Step
+}
+
+// New 创建模拟的命令行并立即开始运行，输出通过管道写入，读取输出之前运行会阻塞在第一次输出处
+func New(cfg Config) *Transport {
+	if cfg.Prompt == "" {
+		cfg.Prompt = "fake# "
+	}
+	if cfg.LineEnd == "" {
+		cfg.LineEnd = "\r\n"
+	}
+	if cfg.MorePrompt == "" {
+		cfg.MorePrompt = "  ---- More ----"
+	}
+	t := &Transport{cfg: cfg, prompt: cfg.Prompt, closed: make(chan struct{}), stopped: make(chan struct{})}
+	t.inR, t.inW = io.Pipe()
+	t.outR, t.outW = io.Pipe()
+	t.in = bufio.NewReader(t.inR)
+	go t.serve()
+	return t
+}
+
+// Handle 注册命令（输入的命令与 cmd 均去掉前后空格后完全相同）对应的步骤
+func (t *Transport) Handle(cmd string, steps ...Step) *Transport {
+	cmd = strings.TrimSpace(cmd)
+	return t.HandleFunc(func(s string) []Step {
+		if s == cmd {
+			return steps
+		}
+		return nil
+	})
+}
+
+// HandleRegex 注册匹配正则表达式的命令对应的步骤
+func (t *Transport) HandleRegex(re *regexp.Regexp, steps ...Step) *Transport {
+	return t.HandleFunc(func(s string) []Step {
+		if re.MatchString(s) {
+			return steps
+		}
+		return nil
+	})
+}
+
+// HandleFunc 注册自定义的匹配规则，f 返回 nil 时表示不匹配，返回空切片时表示匹配、但只输出命令行提示符
+func (t *Transport) HandleFunc(f func(cmd string) []Step) *Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, rule{match: f})
+	return t
+}
+
+// Write 写入输入内容（命令、交互的回答等）
+func (t *Transport) Write(p []byte) (int, error) {
+	return t.inW.Write(p)
+}
+
+// Read 读取输出内容
+func (t *Transport) Read(p []byte) (int, error) {
+	return t.outR.Read(p)
+}
+
+// Close 关闭模拟的命令行，之后读取输出时返回 io.EOF
+func (t *Transport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+		_ = t.inR.Close()
+		_ = t.inW.Close()
+		_ = t.outW.Close()
+	})
+	<-t.stopped
+	return nil
+}
+
+// CloseInput 关闭输入，已写入的输入处理完毕后停止运行（关闭输出），类似输入流结束时 shell 退出
+func (t *Transport) CloseInput() error {
+	return t.inW.Close()
+}
+
+// Inputs 返回已读取到的全部输入（每行一项，不包括换行符），包括命令以及交互的回答
+func (t *Transport) Inputs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.inputs...)
+}
+
+// Prompt 返回当前的命令行提示符
+func (t *Transport) Prompt() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.prompt
+}
+
+// Send 输出指定内容，\n 会被替换为 Config.LineEnd，并按 Config.ChunkSize 分块写入，用于自定义步骤
+func (t *Transport) Send(s string) error {
+	if t.cfg.LineEnd != "\n" {
+		s = strings.ReplaceAll(s, "\n", t.cfg.LineEnd)
+	}
+	return t.sendRaw(s, t.cfg.ChunkSize, t.cfg.ChunkDelay)
+}
+
+func (t *Transport) sendRaw(s string, size int, delay time.Duration) error {
+	for len(s) != 0 {
+		n := len(s)
+		if size > 0 && n > size {
+			n = size
+		}
+		if _, err := t.outW.Write([]byte(s[:n])); err != nil {
+			return err
+		}
+		if s = s[n:]; len(s) != 0 && delay > 0 {
+			if err := t.sleep(delay); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadLine 读取一行输入（不包括换行符），用于自定义步骤
+func (t *Transport) ReadLine() (string, error) {
+	line, err := t.in.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	t.mu.Lock()
+	t.inputs = append(t.inputs, line)
+	t.mu.Unlock()
+	return line, nil
+}
+
+// ReadKey 读取一个字符的输入（如分页时的空格），用于自定义步骤
+func (t *Transport) ReadKey() (byte, error) {
+	return t.in.ReadByte()
+}
+
+func (t *Transport) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-t.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (t *Transport) serve() {
+	defer close(t.stopped)
+	defer func() { _ = t.outW.Close() }()
+
+	if t.cfg.Banner != "" {
+		if t.Send(t.cfg.Banner) != nil {
+			return
+		}
+	}
+	if err := runSteps(t, t.cfg.Before); err != nil && err != errNoPrompt {
+		return
+	}
+	showPrompt := true
+	for {
+		if showPrompt {
+			if t.sendRaw(t.Prompt(), t.cfg.ChunkSize, t.cfg.ChunkDelay) != nil {
+				return
+			}
+		}
+		showPrompt = true
+
+		line, err := t.ReadLine()
+		if err != nil {
+			return
+		}
+		if !t.cfg.NoEcho {
+			if t.Send(line+"\n") != nil {
+				return
+			}
+		}
+		cmd := strings.TrimSpace(line)
+		if cmd == "" {
+			continue
+		}
+
+		if err = runSteps(t, t.match(cmd)); err == errNoPrompt {
+			showPrompt = false
+		} else if err != nil {
+			return
+		}
+	}
+}
+
+func (t *Transport) match(cmd string) []Step {
+	t.mu.Lock()
+	rules := t.rules
+	t.mu.Unlock()
+	for _, r := range rules {
+		if steps := r.match(cmd); steps != nil {
+			return steps
+		}
+	}
+	if t.cfg.Unknown != nil {
+		return t.cfg.Unknown(cmd)
+	}
+	return []Step{Output("% Unknown command: " + cmd + "\n")}
+}
@@ -0,0 +1,134 @@
+package fake
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestReadWriter(t *testing.T, tr *Transport) *core.ReadWriter {
+	rw := core.New(tr, tr, nil, core.Config{ReadConfirmWait: 20 * time.Millisecond})
+	t.Cleanup(func() {
+		_ = tr.Close()
+		rw.Stop()
+	})
+	// 读取欢迎信息以及第一个提示符
+	assert.NoError(t, rw.ReadToEndLine(5*time.Second, nil))
+	return rw
+}
+
+func run(t *testing.T, rw *core.ReadWriter, cmd string, opts ...core.RunOption) *core.Result {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := rw.Run(ctx, cmd, opts...)
+	assert.NoError(t, err)
+	return res
+}
+
+func TestTransport_Output(t *testing.T) {
+	tr := New(Config{Banner: "Welcome\n", Prompt: "sw1# "})
+	tr.Handle("show version", Output("Version 1.0\nUptime 1 day\n"))
+	tr.Handle(" show clock ", Output("10:00:00\n"))
+	tr.HandleRegex(regexp.MustCompile(`^ping `), Output("reply\n"), Delay(200*time.Millisecond), Output("reply\n"))
+	rw := newTestReadWriter(t, tr)
+
+	res := run(t, rw, "show version")
+	assert.Equal(t, "show version", res.Echo)
+	assert.Equal(t, []string{"Version 1.0", "Uptime 1 day"}, res.Lines)
+	assert.Equal(t, "sw1# ", res.Prompt)
+
+	res = run(t, rw, "ping 1.1.1.1")
+	assert.Equal(t, []string{"reply", "reply"}, res.Lines)
+	assert.GreaterOrEqual(t, res.Duration(), 200*time.Millisecond)
+
+	// 注册的命令同样去掉前后空格后匹配
+	res = run(t, rw, "show clock")
+	assert.Equal(t, []string{"10:00:00"}, res.Lines)
+
+	res = run(t, rw, "reload")
+	assert.Equal(t, []string{"% Unknown command: reload"}, res.Lines)
+	assert.Equal(t, []string{"show version", "ping 1.1.1.1", "show clock", "reload"}, tr.Inputs())
+}
+
+func TestTransport_More(t *testing.T) {
+	tr := New(Config{Prompt: "sw1# "})
+	tr.Handle("show run", More("line1\nline2\n", "line3\nline4\n", "line5\n"))
+	rw := newTestReadWriter(t, tr)
+
+	// 内置的分页拦截器自动翻页，输出中不包括分页提示符
+	res := run(t, rw, "show run")
+	assert.Equal(t, []string{"line1", "line2", "line3", "line4", "line5"}, res.Lines)
+	assert.Len(t, res.Interceptions, 2)
+	for _, v := range res.Interceptions {
+		assert.Equal(t, -1, v.Index)
+		assert.Equal(t, " ", v.Input)
+	}
+}
+
+func TestTransport_Chunks(t *testing.T) {
+	// 输出按 3 个字节分包，提示符也会被拆分
+	tr := New(Config{Prompt: "sw1# ", ChunkSize: 3, ChunkDelay: 5 * time.Millisecond})
+	tr.Handle("show clock", Output("12:00:00 UTC\n"))
+	// 提示符在任意位置拆分，且两次写入之间的间隔超过确认间隔
+	tr.Handle("split", Output("done\n"), Chunks(100*time.Millisecond, "sw", "1# "), NoPrompt())
+	rw := newTestReadWriter(t, tr)
+
+	res := run(t, rw, "show clock")
+	assert.Equal(t, []string{"12:00:00 UTC"}, res.Lines)
+	assert.Equal(t, "sw1# ", res.Prompt)
+
+	res = run(t, rw, "split")
+	assert.Equal(t, []string{"done"}, res.Lines)
+	assert.Equal(t, "sw1# ", res.Prompt)
+}
+
+func TestTransport_Ask(t *testing.T) {
+	tr := New(Config{Prompt: "sw1# "})
+	tr.Handle("enable", Ask("Password: ", func(input string) []Step {
+		if input != "secret" {
+			return []Step{Output("% Access denied\n")}
+		}
+		return []Step{SetPrompt("sw1(enable)# ")}
+	}))
+	tr.Handle("reload", Ask("Proceed with reload? [y/n]: ", func(input string) []Step {
+		return []Step{Output("answer: " + input + "\n")}
+	}))
+	rw := newTestReadWriter(t, tr)
+
+	res := run(t, rw, "enable", core.WithInterceptors(interceptor.LastLinePassword(`Password:`, "wrong", false)))
+	assert.Equal(t, []string{"% Access denied"}, res.Lines)
+	assert.Equal(t, "sw1# ", res.Prompt)
+
+	res = run(t, rw, "enable", core.WithInterceptors(interceptor.LastLinePassword(`Password:`, "secret", false)))
+	assert.Len(t, res.Interceptions, 1)
+	assert.Equal(t, "sw1(enable)# ", res.Prompt)
+	assert.Equal(t, "sw1(enable)# ", tr.Prompt())
+
+	res = run(t, rw, "reload", core.WithInterceptors(interceptor.AlwaysNo()))
+	assert.Equal(t, []string{"answer: n"}, res.Lines)
+}
+
+func TestTransport_NoPromptAndDisconnect(t *testing.T) {
+	tr := New(Config{})
+	tr.Handle("hang", Output("working\n"), NoPrompt())
+	tr.Handle("exit", Output("bye\n"), Disconnect())
+	rw := newTestReadWriter(t, tr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	res, err := rw.Run(ctx, "hang")
+	assert.True(t, core.IsTimeout(err), err)
+	assert.Equal(t, []string{"working"}, res.Lines)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out []string
+	assert.NoError(t, rw.Write("exit"))
+	assert.NoError(t, rw.ReadAllContext(ctx, func(lines []string) { out = append(out, lines...) }))
+	assert.Equal(t, "bye", strings.Join(out[len(out)-1:], ""))
+}
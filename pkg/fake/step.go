@@ -0,0 +1,129 @@
+package fake
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Step 执行命令时的一个步骤，返回错误时停止运行（关闭输出）
+type Step func(t *Transport) error
+
+var errNoPrompt = errors.New("no prompt")
+
+// runSteps 依次执行各个步骤，其中有 NoPrompt 时返回 errNoPrompt
+func runSteps(t *Transport, steps []Step) error {
+	var noPrompt bool
+	for _, step := range steps {
+		if err := step(t); err == errNoPrompt {
+			noPrompt = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if noPrompt {
+		return errNoPrompt
+	}
+	return nil
+}
+
+// Output 输出指定内容，\n 会被替换为 Config.LineEnd
+func Output(s string) Step {
+	return func(t *Transport) error {
+		return t.Send(s)
+	}
+}
+
+// Delay 等待一段时间，用于模拟执行耗时较长的命令
+func Delay(d time.Duration) Step {
+	return func(t *Transport) error {
+		return t.sleep(d)
+	}
+}
+
+// Chunks 依次原样输出各个块（不替换换行符），块之间等待 delay，用于模拟在任意位置（如提示符、多字节字符、控制字符中间）分包
+func Chunks(delay time.Duration, chunks ...string) Step {
+	return func(t *Transport) error {
+		for i, s := range chunks {
+			if i > 0 && delay > 0 {
+				if err := t.sleep(delay); err != nil {
+					return err
+				}
+			}
+			if _, err := t.outW.Write([]byte(s)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// More 分页输出，每页之后输出分页提示符（Config.MorePrompt），读取到任意一个字符后输出下一页；读取到 q 或 Q 时结束
+func More(pages ...string) Step {
+	return func(t *Transport) error {
+		for i, page := range pages {
+			if err := t.Send(page); err != nil {
+				return err
+			}
+			if i == len(pages)-1 {
+				break
+			}
+			if err := t.sendRaw(t.cfg.MorePrompt, t.cfg.ChunkSize, t.cfg.ChunkDelay); err != nil {
+				return err
+			}
+			key, err := t.ReadKey()
+			if err != nil {
+				return err
+			}
+			// 清除分页提示符
+			if err = t.sendRaw("\r", 0, 0); err != nil {
+				return err
+			}
+			if key == 'q' || key == 'Q' {
+				return t.Send("\n")
+			}
+		}
+		return nil
+	}
+}
+
+// Ask 输出提示内容（如 "Password: "、"Continue? [Y/N]: "），读取一行输入，然后执行 reply 返回的步骤，输入的内容不回显
+func Ask(prompt string, reply func(input string) []Step) Step {
+	return func(t *Transport) error {
+		if err := t.Send(prompt); err != nil {
+			return err
+		}
+		input, err := t.ReadLine()
+		if err != nil {
+			return err
+		}
+		if err = t.Send("\n"); err != nil {
+			return err
+		}
+		return runSteps(t, reply(input))
+	}
+}
+
+// SetPrompt 修改命令行提示符，如进入配置模式 "fake(config)# "
+func SetPrompt(prompt string) Step {
+	return func(t *Transport) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.prompt = prompt
+		return nil
+	}
+}
+
+// NoPrompt 命令执行完毕后不输出命令行提示符，用于模拟没有结束的命令
+func NoPrompt() Step {
+	return func(t *Transport) error {
+		return errNoPrompt
+	}
+}
+
+// Disconnect 关闭输出，模拟连接断开
+func Disconnect() Step {
+	return func(t *Transport) error {
+		return io.EOF
+	}
+}
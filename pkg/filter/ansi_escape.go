@@ -1,14 +1,68 @@
 package filter
 
+import "bytes"
+
+// ANSI 删除ANSI控制序列（CSI），如颜色、光标移动、清除行等，不处理控制序列对应的内容（需要正确处理时可以使用 Screen）
+func ANSI() IFilter {
+	return ansiFilter(false)
+}
+
+// ANSIKeepSGR 与 ANSI 相同，但保留 SGR 控制序列（颜色、字体），用于通过 style 包解析带样式的输出
+func ANSIKeepSGR() IFilter {
+	return ansiFilter(true)
+}
+
+func ansiFilter(keepSGR bool) IFilter {
+	return &lineFilter{
+		do: func(s []byte, _ []byte) []byte {
+			dst := append(make([]byte, 0, len(s)), s...)
+			var dropArr [][2]int
+			var drop [2]int
+			var found bool
+			for pos := 0; pos < len(dst); {
+				if found, pos, drop = checkAnsiEscape(dst, pos); found {
+					if !keepSGR || dst[drop[1]-1] != 'm' {
+						dropArr = append(dropArr, drop)
+					}
+				} else {
+					pos++
+				}
+			}
+			return dropMultiBytes(dst, dropArr)
+		},
+		preview: func(s []byte) []byte {
+			// 末尾不完整的控制序列需要等待后续的数据
+			if i := bytes.LastIndexByte(s, '\x1b'); i >= 0 && isIncompleteCSI(s[i:]) {
+				return s[:i]
+			}
+			return s
+		},
+	}
+}
+
+// isIncompleteCSI s 为 ESC、ESC [ 或者 ESC [ 加上参数（没有结束字符）
+func isIncompleteCSI(s []byte) bool {
+	if len(s) == 1 {
+		return true
+	}
+	if s[1] != '[' {
+		return false
+	}
+	for _, c := range s[2:] {
+		if c < '\x20' || c > '\x3F' {
+			return false
+		}
+	}
+	return true
+}
+
 // checkAnsiEscape 检查控制字符
 //
 //	参考资料：
 //	https://vt100.net/docs/vt100-ug/chapter3.html#S3.3
 //	https://en.wikipedia.org/wiki/ANSI_escape_code#CSI_(Control_Sequence_Introducer)_sequences
 //
-// TODO 对于修改内容的控制符，应该同时处理对应的内容
-//
-//	由于当前函数是在每次读操作之后调用，涉及修改的内容可能还未被完整的读取到缓冲区，可能导致异常
+// 对于修改内容的控制符（如光标移动、清除行），这里只删除控制符本身，需要正确处理对应的内容时可以使用 Screen
 func checkAnsiEscape(s []byte, pos int) (bool, int, [2]int) {
 	length := len(s)
 	if pos >= length || s[pos] != '\x1b' {
@@ -28,7 +82,6 @@ func checkAnsiEscape(s []byte, pos int) (bool, int, [2]int) {
 			// DCS (Device Control String)
 		case '[':
 			// CSI (Control Sequence Introducer)
-			// TODO: support 24-bit colors, such as `ESC[38;2;⟨r⟩;⟨g⟩;⟨b⟩ m`
 
 			// skip '['
 			pos++
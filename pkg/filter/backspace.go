@@ -2,8 +2,29 @@ package filter
 
 import (
 	"bytes"
+	"unicode/utf8"
 )
 
+// Backspace 处理退格：删除退格以及前面等宽的内容（每个退格对应一列，宽字符占两列），退格只处理所在行内的字符
+//
+//	ARRAY APV负载均衡设备输入内容过长触发收缩时的特殊退格（$ + 退格 + \r\n\r）只删除退格
+func Backspace() IFilter {
+	return &lineFilter{
+		do: func(s []byte, next []byte) []byte {
+			// 下一行的第一个字符仅用于判断是否为特殊退格
+			if len(next) > 0 && next[0] != '\r' {
+				next = nil
+			}
+			dst := make([]byte, 0, len(s)+len(next))
+			dst = backspaceFilter(append(append(dst, s...), next...))
+			return dst[:len(dst)-len(next)]
+		},
+		needNext: func(line []byte) bool {
+			return bytes.HasSuffix(line, []byte("\b\r\n"))
+		},
+	}
+}
+
 // backspaceFilter 处理退格
 //
 //	s 要处理的字符, 会被修改
@@ -31,15 +52,8 @@ func backspaceFilter(s []byte) []byte {
 				// 跳过\r\n\r
 				pos = pos - bsCnt + 3
 			} else {
-				// 删除退格以及前面等长的内容
-				start := pos - bsCnt<<1
-				if start < 0 {
-					start = 0
-				}
-				// 退格只处理所在行内的字符
-				if index := bytes.LastIndexByte(s[:pos], '\n'); index >= start {
-					start = index + 1
-				}
+				// 删除退格以及前面等宽的内容
+				start := backspaceStart(s, pos-bsCnt, bsCnt)
 				length -= dropBytes(s, start, pos)
 				pos = start
 			}
@@ -49,16 +63,26 @@ func backspaceFilter(s []byte) []byte {
 
 	// 处理尾部的退格
 	if bsCnt > 0 {
-		start := pos - bsCnt<<1
-		if start < 0 {
-			start = 0
-		}
-		// 退格只处理所在行内的字符
-		if index := bytes.LastIndexByte(s[:pos], '\n'); index >= start {
-			start = index + 1
-		}
-		length -= dropBytes(s, start, pos)
+		length -= dropBytes(s, backspaceStart(s, pos-bsCnt, bsCnt), pos)
 	}
 
 	return s[:length]
 }
+
+// backspaceStart 从 end 向前回退 cells 列，返回被退格删除的内容的起始位置
+//
+//	按字符回退，宽字符占两列，不会删除半个多字节字符；无法解码为 UTF8 的字节按一列处理；不会越过换行符
+func backspaceStart(s []byte, end, cells int) int {
+	start := end
+	for cells > 0 && start > 0 && s[start-1] != '\n' {
+		r, size := utf8.DecodeLastRune(s[:start])
+		if r == utf8.RuneError && size <= 1 {
+			size = 1
+		} else {
+			cells -= runeWidth(r) - 1
+		}
+		start -= size
+		cells--
+	}
+	return start
+}
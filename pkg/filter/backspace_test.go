@@ -29,3 +29,15 @@ func Test_backspaceFilter(t *testing.T) {
 	dst := backspaceFilter(src)
 	assert.Equal(t, expect, dst)
 }
+
+func Test_backspaceFilter_MultiByte(t *testing.T) {
+	// 宽字符占两列，两个退格删除一个汉字，不会残留半个多字节字符
+	assert.Equal(t, "ab中", string(backspaceFilter([]byte("ab中文\b\b"))))
+	assert.Equal(t, "ab中x", string(backspaceFilter([]byte("ab中文\b\bx"))))
+	assert.Equal(t, "aé", string(backspaceFilter([]byte("aéb\b"))))
+	assert.Equal(t, "", string(backspaceFilter([]byte("中文\b\b\b\b\b"))))
+	// 无法解码的字节按一列处理
+	assert.Equal(t, "ab\xd6", string(backspaceFilter([]byte("ab\xd6\xd0\b"))))
+	// 不会越过换行符
+	assert.Equal(t, "中\n", string(backspaceFilter([]byte("中\n文\b\b\b\b"))))
+}
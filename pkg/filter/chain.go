@@ -0,0 +1,61 @@
+package filter
+
+// IPreviewer 可选接口，在不修改状态的情况下，计算在保留的内容之后追加 p 时未结束的最后一行的过滤结果
+//
+//	Chain 通过该接口将前一个过滤器的 Pending 交给后续的过滤器处理，没有实现该接口的过滤器在计算 Pending 时被跳过
+type IPreviewer interface {
+	Preview(p []byte) []byte
+}
+
+// Chain 依次使用多个过滤器处理，前一个过滤器的输出作为后一个过滤器的输入，可以在默认处理的基础上添加特定设备的处理，如：
+//
+//	filter.Chain(filter.NewDefaultFilter(), filter.Regex(regexp.MustCompile(`\s+$`), ""))
+//
+//	没有指定任何过滤器时，不做任何处理，只拆分行
+func Chain(filters ...IFilter) IFilter {
+	if len(filters) == 0 {
+		filters = []IFilter{LineFunc(nil)}
+	}
+	return &chain{filters: filters}
+}
+
+type chain struct {
+	filters []IFilter
+}
+
+func (c *chain) Write(p []byte) []byte {
+	for _, f := range c.filters {
+		p = f.Write(p)
+	}
+	return p
+}
+
+func (c *chain) Pending() []byte {
+	p := c.filters[0].Pending()
+	for _, f := range c.filters[1:] {
+		p = preview(f, p)
+	}
+	return trimIncompleteRune(p)
+}
+
+func (c *chain) Preview(p []byte) []byte {
+	for _, f := range c.filters {
+		p = preview(f, p)
+	}
+	return p
+}
+
+func (c *chain) Flush() []byte {
+	p := c.filters[0].Flush()
+	for _, f := range c.filters[1:] {
+		p = append(f.Write(p), f.Flush()...)
+	}
+	return p
+}
+
+func preview(f IFilter, p []byte) []byte {
+	if v, ok := f.(IPreviewer); ok {
+		return v.Preview(p)
+	}
+	return p
+}
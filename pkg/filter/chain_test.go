@@ -0,0 +1,160 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChain(t *testing.T) {
+	// 在默认处理的基础上删除残留的分页提示符
+	f := Chain(NewDefaultFilter(), Regex(regexp.MustCompile(`^\s*-+ More -+\s*`), ""))
+	assert.Equal(t, "line1\nline2\n", string(f.Write([]byte("line1\r\n  ---- More ----\x1b[16D\x1b[Kline2\r\n"))))
+	assert.Equal(t, "", string(f.Write([]byte("\x1b[31m  ---- More -"))))
+	assert.Equal(t, "", string(f.Pending()))
+	assert.Equal(t, "", string(f.Write([]byte("---\x1b[0m sw1#"))))
+	assert.Equal(t, "sw1#", string(f.Pending()))
+	assert.Equal(t, "sw1#", string(f.Flush()))
+	assert.Equal(t, "", string(f.Pending()))
+
+	// 各个步骤的顺序
+	f = Chain(Backspace(), CRLF(CrTrimModeBeginOfLine), UTF8Replace(), ANSI(), DropControlChars(), ExpandTabs(4))
+	assert.Equal(t, "a   b\n", string(filterAll(f, []byte("\x1b[1ma\x07\tb\x1b[0m\r\n"))))
+	assert.Equal(t, "xyz\n", string(filterAll(f, []byte("abc\b\b\b\x01xyz\xef\xbf\xbd\r\n"))))
+
+	// 保留 SGR 控制序列
+	f = NewDefaultFilter(Options{Crlf: true, AnsiEscape: true, KeepSGR: true})
+	assert.Equal(t, "\x1b[31mred\x1b[0m\n", string(filterAll(f, []byte("\x1b[2K\x1b[31mred\x1b[0m\r\n"))))
+
+	// 没有指定过滤器时只拆分行
+	f = Chain()
+	assert.Equal(t, "a\r\n", string(f.Write([]byte("a\r\nb\x1b"))))
+	assert.Equal(t, "b\x1b", string(f.Pending()))
+}
+
+func ExampleChain() {
+	// 在默认处理的基础上删除行尾的空白字符
+	f := Chain(NewDefaultFilter(), Regex(regexp.MustCompile(`\s+$`), ""))
+	fmt.Printf("%q\n", f.Write([]byte("\x1b[1mline1\x1b[0m   \r\nline2\t\r\n")))
+	// Output: "line1\nline2\n"
+}
+
+func TestChain_Screen(t *testing.T) {
+	f := Chain(NewScreen(), Regex(regexp.MustCompile(`\d+%`), "N%"), LineFunc(bytes.ToUpper))
+	assert.Equal(t, "COPYING N%\n", string(f.Write([]byte("copying 10%\rcopying 100%\r\n"))))
+	assert.Equal(t, "", string(f.Write([]byte("sw1# "))))
+	assert.Equal(t, "SW1# ", string(f.Pending()))
+	assert.Equal(t, "SW1# ", string(f.Flush()))
+	assert.Equal(t, "", string(f.Pending()))
+}
+
+func TestChain_Chunked(t *testing.T) {
+	var all []byte
+	for _, val := range append(strArr, screenArr...) {
+		all = append(all, val[0]...)
+	}
+	factories := []func() IFilter{
+		func() IFilter {
+			return Chain(NewDefaultFilter(), Regex(regexp.MustCompile(`[0-9]+`), "<$0>"), DropControlChars(), ExpandTabs(0))
+		},
+		func() IFilter {
+			return Chain(NewScreen(), Chain(CRLF(CrTrimModeOnlyCr), LineFunc(bytes.TrimSpace)))
+		},
+	}
+
+	rnd := newChunkRand(t)
+	for _, factory := range factories {
+		expect := filterAll(factory(), all)
+		for i := 0; i < 200; i++ {
+			assert.Equal(t, string(expect), string(filterChunked(factory(), all, rnd)))
+		}
+	}
+}
+
+func TestDropControlChars(t *testing.T) {
+	assert.Equal(t, "abc\tdef\n", string(filterAll(DropControlChars(), []byte("a\x00b\x1bc\tdef\r\x7f\n"))))
+}
+
+func TestExpandTabs(t *testing.T) {
+	assert.Equal(t, "a       b\nab  中  c\n    x", string(append(
+		ExpandTabs(0).Write([]byte("a\tb\n")), filterAll(ExpandTabs(4), []byte("ab\t中\tc\n\tx"))...)))
+}
+
+func TestRegex(t *testing.T) {
+	f := Regex(regexp.MustCompile(`(\d+)\.(\d+)`), "$2.$1")
+	assert.Equal(t, "2.1\n4.3", string(filterAll(f, []byte("1.2\n3.4"))))
+	// 每一行单独匹配
+	f = Regex(regexp.MustCompile(`^\s+|\s+$`), "")
+	assert.Equal(t, "a\nb\n", string(filterAll(f, []byte("  a  \n\tb \n"))))
+}
+
+func TestChain_LongPending(t *testing.T) {
+	// 没有换行符的长输出，Pending 只处理末尾部分，完整的内容在 Flush 时返回
+	line := bytes.Repeat([]byte("\x1b[31m{\"k\":\"值\"}\x1b[0m"), 4096)
+	f := NewDefaultFilter()
+	for p := line; len(p) > 0; {
+		n := min(len(p), 1000)
+		assert.Empty(t, f.Write(p[:n]))
+		p = p[n:]
+	}
+	pending := f.Pending()
+	assert.LessOrEqual(t, len(pending), MaxPendingPreview)
+	assert.True(t, bytes.HasSuffix(pending, []byte(`{"k":"值"}{"k":"值"}`)))
+	// 开头不是被截断的控制序列或多字节字符
+	assert.True(t, utf8.Valid(pending))
+	assert.NotContains(t, string(pending), "\x1b")
+	assert.True(t, bytes.HasPrefix(pending, []byte(`{"k":"值"}`)), string(pending[:20]))
+	assert.Equal(t, bytes.Repeat([]byte(`{"k":"值"}`), 4096), f.Flush())
+}
+
+// benchData 模拟设备的输出：颜色、分页、退格、回车、制表符
+var benchData = bytes.Repeat([]byte("\x1b[32mGigabitEthernet0/0/1\x1b[0m\tup\tup\t  1000M\r\n"+
+	"  ---- More ----\b\b\b\b\b\b\b\b\b\b\b\b\b\b\b\b                \b\b\b\b\b\b\b\b\b\b\b\b\b\b\b\b"+
+	"Vlan100\t\tdown\r\ndesc \xe4\xb8\xad\xe6\x96\x87\xef\xbf\xbd\r\n"), 64)
+
+// benchLongLine 没有换行符的长输出，如单行的大段 JSON
+var benchLongLine = bytes.Repeat([]byte(`{"name":"GigabitEthernet0/0/1","status":"up","speed":1000},`), 16*1024)
+
+func BenchmarkStages(b *testing.B) {
+	stages := []struct {
+		name    string
+		factory func() IFilter
+	}{
+		{"Backspace", Backspace},
+		{"CRLF", func() IFilter { return CRLF(CrTrimModeBeginOfLine) }},
+		{"UTF8Replace", UTF8Replace},
+		{"ANSI", ANSI},
+		{"Regex", func() IFilter { return Regex(regexp.MustCompile(`\s+$`), "") }},
+		{"DropControlChars", DropControlChars},
+		{"ExpandTabs", func() IFilter { return ExpandTabs(8) }},
+		{"LineFunc", func() IFilter { return LineFunc(nil) }},
+		{"Default", func() IFilter { return NewDefaultFilter() }},
+		{"Screen", func() IFilter { return NewScreen() }},
+	}
+	for _, stage := range stages {
+		b.Run(stage.name, func(b *testing.B) {
+			benchFilter(b, stage.factory(), benchData, 4096)
+		})
+		// 长行被拆分为多次读取，每次读取后计算 Pending
+		b.Run(stage.name+"/LongLine", func(b *testing.B) {
+			benchFilter(b, stage.factory(), benchLongLine, 512)
+		})
+	}
+}
+
+func benchFilter(b *testing.B, f IFilter, data []byte, chunk int) {
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for p := data; len(p) > 0; {
+			n := min(len(p), chunk)
+			f.Write(p[:n])
+			f.Pending()
+			p = p[n:]
+		}
+		f.Flush()
+	}
+}
@@ -0,0 +1,53 @@
+package filter
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// DropControlChars 删除除换行符、制表符以外的控制字符（包括 \r、退格、ESC、DEL），通常放在 Backspace、CRLF、ANSI 之后，删除这些步骤未处理的控制字符
+func DropControlChars() IFilter {
+	return LineFunc(func(line []byte) []byte {
+		n := 0
+		for _, c := range line {
+			if c >= 0x20 && c != 0x7F || c == '\t' {
+				line[n] = c
+				n++
+			}
+		}
+		return line[:n]
+	})
+}
+
+// ExpandTabs 将制表符替换为空格，对齐到 n 的整数倍的列（宽字符占两列），n 小于等于 0 时默认为 8
+func ExpandTabs(n int) IFilter {
+	if n <= 0 {
+		n = 8
+	}
+	return LineFunc(func(line []byte) []byte {
+		if bytes.IndexByte(line, '\t') < 0 {
+			return line
+		}
+		dst := make([]byte, 0, len(line)+n)
+		col := 0
+		for len(line) > 0 {
+			if line[0] == '\t' {
+				for spaces := n - col%n; spaces > 0; spaces-- {
+					dst = append(dst, ' ')
+					col++
+				}
+				line = line[1:]
+				continue
+			}
+			r, size := utf8.DecodeRune(line)
+			if r == utf8.RuneError && size == 1 {
+				col++
+			} else {
+				col += runeWidth(r)
+			}
+			dst = append(dst, line[:size]...)
+			line = line[size:]
+		}
+		return dst
+	})
+}
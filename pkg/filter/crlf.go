@@ -10,6 +10,24 @@ const (
 	CrTrimModeBeginOfLine        // 遇到单个 \r 时，清除 \r 及其左侧的内容，直到行首
 )
 
+// CRLF 处理回车换行：\r\n 转换为 \n，单独的 \r 按 crTrimMode 处理，未结束的最后一行中的 \r 不做处理（可能是被拆分的 \r\n）
+//
+//	部分H3C设备的 \r\r\n、\r\r\n\NUL 也转换为 \n
+func CRLF(crTrimMode int) IFilter {
+	var dropNul bool // 上一行以 \r\r\n 结尾，需要删除下一行开头的 \NUL
+	return &lineFilter{
+		do: func(s []byte, _ []byte) []byte {
+			if dropNul && len(s) > 0 && s[0] == '\x00' {
+				s = s[1:]
+			}
+			return crlfFilter(append(make([]byte, 0, len(s)), s...), crTrimMode)
+		},
+		done: func(line []byte) {
+			dropNul = bytes.HasSuffix(line, []byte("\r\r\n"))
+		},
+	}
+}
+
 // crlfFilter 处理回车换行
 //
 //	s 要处理的字符, 会被修改
@@ -1,14 +1,22 @@
 package filter
 
+// IFilter 流式字符过滤器
+//
+//	读取到的数据可能在任意位置被拆分（如控制序列、\r\n、多字节字符被拆分到两次读取中），过滤器需要在内部保留无法确定的内容，
+//	保证同一段数据无论如何拆分，依次调用 Write 的返回值再加上 Flush 的返回值都是相同的
+//	过滤器是有状态的，每个会话需要使用单独的过滤器
 type IFilter interface {
-	// Do 过滤字符, 返回过滤后的字符, 不修改源数据
-	Do(s []byte) []byte
+	// Write 写入新读取到的数据，返回已经确定的过滤结果（以 \n 结尾的完整的行），不修改源数据
+	Write(p []byte) []byte
+	// Pending 返回未结束的最后一行当前的过滤结果（不包括换行符），后续的数据（如退格、回车）可能会修改该行，不修改过滤器的状态
+	//	未结束的最后一行很长时可以只返回末尾部分（参考 MaxPendingPreview）
+	Pending() []byte
+	// Flush 结束当前行，返回未结束的最后一行的过滤结果，并清空内部保留的内容
+	Flush() []byte
 }
 
-// DefaultFilter 默认字符过滤器
-type DefaultFilter struct {
-	opt Options
-}
+// Factory 创建过滤器，每个输出流（如 stdout、stderr）以及每次重连都会创建单独的过滤器
+type Factory func() IFilter
 
 type Options struct {
 	Crlf        bool // 是否处理回车、换行
@@ -16,6 +24,7 @@ type Options struct {
 	Backspace   bool // 是否处理退格
 	AnsiEscape  bool // 是否处理ANSI转义字符
 	Utf8Replace bool // 是否处理UTF8替换字符
+	KeepSGR     bool // 处理ANSI转义字符时是否保留 SGR 控制序列（颜色、字体），仅在AnsiEscape为true时有效
 }
 
 func (o Options) IsNothingToDo() bool {
@@ -30,51 +39,31 @@ var DefaultOptions = Options{
 	Utf8Replace: true,
 }
 
+// NewDefaultFilter 默认字符过滤器，按以下顺序处理（未开启的步骤被跳过），需要在默认处理的基础上添加其他处理时可以使用 Chain 组合：
+//
+//	Chain(Backspace(), CRLF(opt.CrTrimMode), UTF8Replace(), ANSI() 或 ANSIKeepSGR())
 func NewDefaultFilter(opt ...Options) IFilter {
+	o := DefaultOptions
 	if len(opt) > 0 {
-		return DefaultFilter{opt: opt[0]}
+		o = opt[0]
 	}
-	return DefaultFilter{opt: DefaultOptions}
-}
 
-func (f DefaultFilter) Do(src []byte) []byte {
-	if len(src) == 0 || f.opt.IsNothingToDo() {
-		return src
+	var filters []IFilter
+	if o.Backspace {
+		filters = append(filters, Backspace())
 	}
-
-	// 为避免修改源数据，拷贝一份
-	dst := make([]byte, len(src))
-	copy(dst, src)
-
-	if f.opt.Backspace {
-		dst = backspaceFilter(dst)
+	if o.Crlf {
+		filters = append(filters, CRLF(o.CrTrimMode))
 	}
-	if f.opt.Crlf {
-		dst = crlfFilter(dst, f.opt.CrTrimMode)
+	if o.Utf8Replace {
+		filters = append(filters, UTF8Replace())
 	}
-
-	// 其他字符
-	var dropArr [][2]int
-	var drop [2]int
-	var found bool
-	for pos := 0; pos < len(dst); {
-		if f.opt.Utf8Replace {
-			found, pos, drop = checkUTF8ReplaceChar(dst, pos)
-			if found {
-				dropArr = append(dropArr, drop)
-				continue
-			}
-		}
-
-		if f.opt.AnsiEscape {
-			found, pos, drop = checkAnsiEscape(dst, pos)
-			if found {
-				dropArr = append(dropArr, drop)
-				continue
-			}
+	if o.AnsiEscape {
+		if o.KeepSGR {
+			filters = append(filters, ANSIKeepSGR())
+		} else {
+			filters = append(filters, ANSI())
 		}
-
-		pos++
 	}
-	return dropMultiBytes(dst, dropArr)
+	return Chain(filters...)
 }
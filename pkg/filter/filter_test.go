@@ -1,10 +1,16 @@
 package filter
 
 import (
+	"flag"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"testing"
+	"time"
 )
 
+// chunkSeed 随机拆分数据时使用的随机数种子，为 0 时使用当前时间，用于重现失败的测试：go test ./pkg/filter -args -chunk.seed=N
+var chunkSeed = flag.Int64("chunk.seed", 0, "random seed for chunked filter tests, 0 means current time")
+
 var (
 	strArr = [][]string{
 		{"abcc\b", "abc"},
@@ -26,10 +32,83 @@ var (
 	}
 )
 
+// filterAll 一次写入全部数据
+func filterAll(f IFilter, src []byte) []byte {
+	return append(f.Write(src), f.Flush()...)
+}
+
+// newChunkRand 创建随机拆分数据时使用的随机数生成器，并输出使用的种子
+func newChunkRand(t *testing.T) *rand.Rand {
+	t.Helper()
+	seed := *chunkSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("chunk seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+// filterChunked 将数据随机拆分后依次写入
+func filterChunked(f IFilter, src []byte, rnd *rand.Rand) []byte {
+	var dst []byte
+	for len(src) > 0 {
+		n := rnd.Intn(len(src)) + 1
+		if rnd.Intn(2) == 0 && n > 3 {
+			// 更多地拆分成较小的块
+			n = rnd.Intn(3) + 1
+		}
+		dst = append(dst, f.Write(src[:n])...)
+		src = src[n:]
+	}
+	return append(dst, f.Flush()...)
+}
+
 func TestDefaultFilter(t *testing.T) {
-	filter := NewDefaultFilter()
 	for _, val := range strArr {
-		assert.Equal(t, []byte(val[1]), filter.Do([]byte(val[0])))
+		assert.Equal(t, []byte(val[1]), filterAll(NewDefaultFilter(), []byte(val[0])))
+	}
+}
+
+func TestDefaultFilter_Pending(t *testing.T) {
+	f := NewDefaultFilter()
+	assert.Equal(t, "abc\n", string(f.Write([]byte("abc\r\ndef\x1b[3"))))
+	assert.Equal(t, "def", string(f.Pending()))
+	assert.Equal(t, "", string(f.Write([]byte("1mxyz\b"))))
+	assert.Equal(t, "defxy", string(f.Pending()))
+	// 不完整的多字节字符
+	assert.Equal(t, "", string(f.Write([]byte("\xe4\xb8"))))
+	assert.Equal(t, "defxy", string(f.Pending()))
+	assert.Equal(t, "defxy中\n", string(f.Write([]byte("\xad\r\n"))))
+	assert.Equal(t, "", string(f.Pending()))
+
+	// 丢弃未结束的行
+	f.Write([]byte("sw1# "))
+	assert.Equal(t, "sw1# ", string(f.Flush()))
+	assert.Equal(t, "show version\n", string(f.Write([]byte("show version\r\n"))))
+
+	// ARRAY APV 的特殊退格需要等待下一个字符
+	assert.Equal(t, "", string(f.Write([]byte("abc$\b\b\b\r\n"))))
+	assert.Equal(t, "abc$\n", string(f.Write([]byte("\r"))))
+}
+
+func TestDefaultFilter_Chunked(t *testing.T) {
+	var all []byte
+	for _, val := range strArr {
+		all = append(all, val[0]...)
+	}
+	samples := append([][]byte{all}, []byte("\x1b[31mred\x1b[0m\r\n\xef\xbf\xbd\xef\xbf\xbd中文\r\r\n\x00abc\b\b\r\n"))
+	for _, val := range strArr {
+		samples = append(samples, []byte(val[0]))
+	}
+
+	rnd := newChunkRand(t)
+	for _, sample := range samples {
+		expect := filterAll(NewDefaultFilter(), sample)
+		screen := filterAll(NewScreen(), sample)
+		for i := 0; i < 200; i++ {
+			assert.Equal(t, string(expect), string(filterChunked(NewDefaultFilter(), sample, rnd)), "%q", sample)
+			assert.Equal(t, string(screen), string(filterChunked(NewScreen(), sample, rnd)), "%q", sample)
+		}
 	}
 }
 
@@ -38,7 +117,8 @@ func BenchmarkDefaultFilter(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		for _, val := range strArr {
-			filter.Do([]byte(val[0]))
+			filter.Write([]byte(val[0]))
 		}
+		filter.Flush()
 	}
 }
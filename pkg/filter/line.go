@@ -0,0 +1,159 @@
+package filter
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// MaxPendingPreview 计算 Pending 时最多处理的未结束的最后一行的原始数据长度，超过时只处理末尾部分
+//
+//	没有换行符的长输出（如进度条、单行的大段 JSON）每次读取后都会计算 Pending，处理整行会导致耗时随行长度平方增长；
+//	Pending 主要用于匹配提示符、分页等，末尾部分即可满足，完整的内容仍然在读取到换行符或 Flush 时返回；小于等于 0 时不限制
+var MaxPendingPreview = 4096
+
+// lineFilter 按行处理的过滤器：在内部保留未结束的最后一行的原始数据，读取到换行符后再处理整行，因此数据被拆分时不影响过滤结果
+type lineFilter struct {
+	// do 处理一行或多行（最后一行可能没有换行符），next 为下一行的第一个字符（仅 needNext 返回 true 时），不修改源数据
+	do func(s []byte, next []byte) []byte
+	// needNext 一行（以 \n 结尾）需要根据下一行的第一个字符才能确定如何处理，可以为 nil
+	needNext func(line []byte) bool
+	// done 一行处理完毕后的回调，用于更新状态，可以为 nil
+	done func(line []byte)
+	// preview 处理未结束的最后一行的预览结果（如删除不完整的控制序列），可以为 nil
+	preview func(s []byte) []byte
+
+	pending []byte // 未结束的最后一行的原始数据
+}
+
+func (f *lineFilter) Write(p []byte) []byte {
+	f.pending = append(f.pending, p...)
+
+	var out []byte
+	for {
+		i := bytes.IndexByte(f.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := f.pending[:i+1]
+		var next []byte
+		if f.needNext != nil && f.needNext(line) {
+			if i+1 == len(f.pending) {
+				// 需要根据下一个字符才能确定如何处理，等待下次读取
+				break
+			}
+			next = f.pending[i+1 : i+2]
+		}
+		out = append(out, f.do(line, next)...)
+		if f.done != nil {
+			f.done(line)
+		}
+		f.pending = f.pending[i+1:]
+	}
+
+	// 已经处理的内容不再保留，避免 pending 底层的数组一直增长
+	if len(f.pending) == 0 {
+		f.pending = nil
+	} else if cap(f.pending) > 4096 && len(f.pending) < cap(f.pending)/4 {
+		f.pending = append([]byte(nil), f.pending...)
+	}
+	return out
+}
+
+func (f *lineFilter) Pending() []byte {
+	return trimIncompleteRune(f.Preview(nil))
+}
+
+// Preview 返回在保留的内容之后追加 p 时，未结束的最后一行的过滤结果，不修改状态
+func (f *lineFilter) Preview(p []byte) []byte {
+	if len(f.pending)+len(p) == 0 {
+		return nil
+	}
+	s := f.do(previewTail(f.pending, p, MaxPendingPreview), nil)
+	if i := bytes.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	if f.preview != nil {
+		s = f.preview(s)
+	}
+	return s
+}
+
+func (f *lineFilter) Flush() []byte {
+	if len(f.pending) == 0 {
+		return nil
+	}
+	out := f.do(f.pending, nil)
+	if f.done != nil {
+		f.done(f.pending)
+	}
+	f.pending = nil
+	return out
+}
+
+// LineFunc 使用自定义函数按行过滤，可以在 Chain 中添加特定设备的处理
+//
+//	fn 的参数为不包括换行符的一行内容（未结束的最后一行也会调用，用于计算 Pending），可以直接修改并返回；fn 为 nil 时不做任何处理，只拆分行
+func LineFunc(fn func(line []byte) []byte) IFilter {
+	return &lineFilter{do: eachLine(fn)}
+}
+
+// eachLine 依次使用 fn 处理每一行（不包括换行符），不修改源数据
+func eachLine(fn func(line []byte) []byte) func(s []byte, next []byte) []byte {
+	return func(s []byte, _ []byte) []byte {
+		dst := make([]byte, 0, len(s))
+		for len(s) > 0 {
+			line := s
+			if i := bytes.IndexByte(s, '\n'); i >= 0 {
+				line = s[:i]
+			}
+			s = s[len(line):]
+			if fn != nil {
+				dst = append(dst, fn(append([]byte(nil), line...))...)
+			} else {
+				dst = append(dst, line...)
+			}
+			if len(s) > 0 {
+				dst = append(dst, '\n')
+				s = s[1:]
+			}
+		}
+		return dst
+	}
+}
+
+// previewTail 返回 pending 之后追加 p 的内容，pending 中没有换行符且总长度超过 max 时只返回末尾部分，
+// 截取的开头不会是被截断的控制序列或多字节字符（开头的退格只会删除截取范围内的内容）
+func previewTail(pending, p []byte, max int) []byte {
+	if max <= 0 || len(pending)+len(p) <= max || bytes.IndexByte(pending, '\n') >= 0 {
+		return append(pending[:len(pending):len(pending)], p...)
+	}
+
+	cut := min(len(pending)+len(p)-max, len(pending))
+	// 开头在控制序列中间时，从控制序列开始的位置截取
+	from := cut - min(cut, maxCSILen)
+	if i := bytes.LastIndexByte(pending[from:cut], '\x1b'); i >= 0 && isIncompleteCSI(pending[from+i:cut]) {
+		cut = from + i
+	}
+	for cut < len(pending) && !utf8.RuneStart(pending[cut]) {
+		cut++
+	}
+	return append(pending[cut:len(pending):len(pending)], p...)
+}
+
+// maxCSILen 向前查找被截断的控制序列时的最大长度
+const maxCSILen = 32
+
+// trimIncompleteRune 删除末尾不完整的多字节字符，这部分内容需要等待后续的数据
+func trimIncompleteRune(s []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(s); i++ {
+		if c := s[len(s)-i]; c >= 0xC0 {
+			if !utf8.FullRune(s[len(s)-i:]) {
+				s = s[:len(s)-i]
+			}
+			break
+		} else if c < 0x80 {
+			break
+		}
+	}
+	return s
+}
@@ -0,0 +1,13 @@
+package filter
+
+import "regexp"
+
+// Regex 按行替换匹配正则表达式的内容，repl 的语法与 regexp.Regexp.ReplaceAll 相同（支持 $1 等引用），repl 为空时删除匹配的内容
+//
+//	每一行单独匹配，不包括换行符；未结束的最后一行（如命令行提示符）也会被处理
+func Regex(re *regexp.Regexp, repl string) IFilter {
+	b := []byte(repl)
+	return LineFunc(func(line []byte) []byte {
+		return re.ReplaceAll(line, b)
+	})
+}
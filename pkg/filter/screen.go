@@ -0,0 +1,713 @@
+package filter
+
+import (
+	"bytes"
+	"golang.org/x/text/width"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// ScreenOptions 虚拟终端的配置
+type ScreenOptions struct {
+	Rows       int  // 屏幕高度，应与终端高度一致（如 SshShellConfig.TermHeight），默认值 200
+	Cols       int  // 屏幕宽度，应与终端宽度一致（如 SshShellConfig.TermWidth），超过宽度时自动换行，默认值 256
+	Scrollback int  // 保留的滚动出屏幕的行数，默认值 1000，小于 0 时不保留
+	NoAutoCR   bool // 换行符（\n）不同时回到行首，默认换行时同时回到行首，避免设备只输出 \n 时内容错位
+}
+
+// Screen 虚拟终端（VT100/xterm 的常用子集），维护虚拟屏幕和光标位置，用于处理通过光标定位重绘的输出（如进度条、top、Junos | refresh、H3C 行编辑）
+//
+//	支持的控制字符：退格、制表符、回车、换行；支持的控制序列：光标移动、保存/恢复光标、清除行/屏幕、插入/删除字符和行、滚动区域、备用屏幕；
+//	其他控制序列（颜色、OSC、DCS 等）被忽略
+//
+//	输出规则：
+//	1.换行时，光标所在行及其上方有变化的行依次作为完整的行输出，自动换行（超过屏幕宽度）的多行合并为一行
+//	2.光标向下移动时，光标上方有变化的行依次作为完整的行输出
+//	3.光标所在行为未结束的最后一行，不包括光标之后的空白
+//
+//	Screen 是有状态的，每个会话需要使用单独的 Screen；在 Chain 中使用时应作为第一个过滤器
+type Screen struct {
+	opt        ScreenOptions
+	mu         sync.Mutex
+	rows       []*screenLine
+	alt        []*screenLine // 切换到备用屏幕时保存的主屏幕
+	row, col   int
+	wrapNext   bool // 光标已到达行尾，写入下一个字符前自动换行
+	top        int  // 滚动区域的第一行
+	bottom     int  // 滚动区域的最后一行
+	saved      [2]int
+	scrollback [][]byte
+
+	state  int
+	params []byte // CSI 参数
+	utf8   []byte // 未完整的 UTF8 字符
+
+	out     [][]byte // 本次输出的完整的行
+	version int      // 屏幕内容或光标位置变化时增加
+	flushed int      // Flush 时的版本号，之后没有变化时当前行视为已输出
+}
+
+type screenLine struct {
+	cells   []screenCell
+	wrapped bool // 超过屏幕宽度后自动换行到下一行
+	dirty   bool // 有尚未输出的变化
+}
+
+// screenCell 屏幕上的一个字符，r 为 0 时表示空白
+type screenCell struct {
+	r    rune
+	raw  bool // 不是合法的 UTF8 字符（如 GBK 编码），r 为原始的单个字节
+	wide bool // 宽字符（如中文）的第二列，不输出
+}
+
+const (
+	screenGround = iota
+	screenEscape
+	screenEscapeSkip // 跳过 ESC 之后的一个字符，如 ESC ( B
+	screenCSI
+	screenString    // OSC、DCS、SOS、PM、APC，直到 BEL 或 ST
+	screenStringEsc // 字符串中的 ESC，之后为 \ 时结束
+)
+
+func NewScreen(opt ...ScreenOptions) *Screen {
+	s := &Screen{}
+	if len(opt) > 0 {
+		s.opt = opt[0]
+	}
+	if s.opt.Rows <= 0 {
+		s.opt.Rows = 200
+	}
+	if s.opt.Cols <= 0 {
+		s.opt.Cols = 256
+	}
+	if s.opt.Scrollback == 0 {
+		s.opt.Scrollback = 1000
+	}
+	s.reset()
+	return s
+}
+
+// Reset 清空屏幕、滚动记录，并恢复初始状态
+func (s *Screen) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+	s.scrollback = nil
+}
+
+func (s *Screen) reset() {
+	s.rows = s.newRows(s.opt.Rows)
+	s.alt = nil
+	s.row, s.col, s.wrapNext = 0, 0, false
+	s.top, s.bottom = 0, s.opt.Rows-1
+	s.saved = [2]int{}
+	s.state, s.params, s.utf8 = screenGround, s.params[:0], s.utf8[:0]
+	s.version++
+}
+
+func (s *Screen) newRows(n int) []*screenLine {
+	rows := make([]*screenLine, n)
+	for i := range rows {
+		rows[i] = &screenLine{cells: make([]screenCell, s.opt.Cols)}
+	}
+	return rows
+}
+
+// Write 返回新的完整的行（以 \n 结尾）
+func (s *Screen) Write(p []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range p {
+		s.feed(b)
+	}
+	var buf bytes.Buffer
+	for _, line := range s.out {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	s.out = nil
+	return buf.Bytes()
+}
+
+// Pending 返回当前行（光标所在行）的内容，Flush 之后屏幕内容和光标位置都没有变化时返回空
+func (s *Screen) Pending() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.version == s.flushed {
+		return nil
+	}
+	return s.current()
+}
+
+// Flush 返回当前行的内容，并将当前行及其上方的行标记为已输出，屏幕内容和光标位置保持不变
+func (s *Screen) Flush() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.version == s.flushed {
+		return nil
+	}
+	current := s.current()
+	for _, line := range s.rows[:s.row+1] {
+		line.dirty = false
+	}
+	s.flushed = s.version
+	return current
+}
+
+// Display 返回屏幕上显示的内容，不包括末尾的空行
+func (s *Screen) Display() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := len(s.rows)
+	for end > 0 && len(s.rows[end-1].render(-1)) == 0 && end-1 != s.row {
+		end--
+	}
+	display := make([]string, end)
+	for i := range display {
+		display[i] = string(s.rows[i].render(-1))
+	}
+	return display
+}
+
+// Scrollback 返回滚动出屏幕的行
+func (s *Screen) Scrollback() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := make([]string, len(s.scrollback))
+	for i, line := range s.scrollback {
+		lines[i] = string(line)
+	}
+	return lines
+}
+
+// Cursor 返回光标位置（从 0 开始）
+func (s *Screen) Cursor() (row, col int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.row, s.col
+}
+
+// current 当前行的内容，包括光标之前的空白（如提示符末尾的空格）
+func (s *Screen) current() []byte {
+	var buf []byte
+	// 自动换行的上一行属于当前行
+	start := s.row
+	for start > 0 && s.rows[start-1].wrapped && s.rows[start-1].dirty {
+		start--
+	}
+	for i := start; i < s.row; i++ {
+		buf = append(buf, s.rows[i].render(s.opt.Cols)...)
+	}
+	col := s.col
+	if s.wrapNext {
+		col = s.opt.Cols
+	}
+	return append(buf, s.rows[s.row].render(col)...)
+}
+
+// render 输出一行的内容，不包括 minCols 列之后的空白
+func (l *screenLine) render(minCols int) []byte {
+	end := len(l.cells)
+	for end > 0 && end > minCols && l.cells[end-1].isBlank() {
+		end--
+	}
+	buf := make([]byte, 0, end)
+	for _, c := range l.cells[:end] {
+		switch {
+		case c.wide:
+		case c.r == 0:
+			buf = append(buf, ' ')
+		case c.raw:
+			buf = append(buf, byte(c.r))
+		default:
+			buf = utf8.AppendRune(buf, c.r)
+		}
+	}
+	return buf
+}
+
+func (c screenCell) isBlank() bool {
+	return c.r == 0 || c.r == ' ' && !c.raw
+}
+
+func (l *screenLine) clear(from, to int) {
+	for i := from; i < to && i < len(l.cells); i++ {
+		l.cells[i] = screenCell{}
+	}
+}
+
+func (l *screenLine) isBlank() bool {
+	for _, c := range l.cells {
+		if !c.isBlank() {
+			return false
+		}
+	}
+	return true
+}
+
+// emit 依次输出 [0, end) 中有变化的行，自动换行的多行合并为一行；最后一行自动换行到第 end 行时不输出
+func (s *Screen) emit(end int) {
+	for i := 0; i < end; i++ {
+		j := i
+		for s.rows[j].wrapped && j+1 < len(s.rows) {
+			j++
+		}
+		if j >= end {
+			return
+		}
+		var dirty bool
+		for k := i; k <= j; k++ {
+			dirty = dirty || s.rows[k].dirty
+		}
+		if dirty {
+			var line []byte
+			for k := i; k <= j; k++ {
+				line = append(line, s.rows[k].render(-1)...)
+				s.rows[k].dirty = false
+			}
+			s.out = append(s.out, line)
+		}
+		i = j
+	}
+}
+
+func (s *Screen) touch() {
+	s.version++
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case screenEscape:
+		s.escape(b)
+		return
+	case screenEscapeSkip:
+		s.state = screenGround
+		return
+	case screenCSI:
+		switch {
+		case b >= 0x20 && b <= 0x3F:
+			s.params = append(s.params, b)
+		case b >= 0x40 && b <= 0x7E:
+			s.state = screenGround
+			s.csi(b)
+			s.params = s.params[:0]
+		case b == 0x1b:
+			s.params = s.params[:0]
+			s.state = screenEscape
+		case b < 0x20:
+			// CSI 中间的控制字符直接执行
+			s.control(b)
+		default:
+			s.params = s.params[:0]
+			s.state = screenGround
+		}
+		return
+	case screenString:
+		switch b {
+		case 0x07:
+			s.state = screenGround
+		case 0x1b:
+			s.state = screenStringEsc
+		}
+		return
+	case screenStringEsc:
+		if b == '\\' {
+			s.state = screenGround
+		} else {
+			s.state = screenString
+		}
+		return
+	}
+
+	if len(s.utf8) != 0 || b >= 0x80 {
+		s.utf8 = append(s.utf8, b)
+		for len(s.utf8) != 0 && utf8.FullRune(s.utf8) {
+			r, size := utf8.DecodeRune(s.utf8)
+			if r == utf8.RuneError && size == 1 {
+				s.put(rune(s.utf8[0]), true, 1)
+			} else {
+				s.put(r, false, runeWidth(r))
+			}
+			s.utf8 = s.utf8[:copy(s.utf8, s.utf8[size:])]
+			// 非 ASCII 字符之后的 ASCII 字符，按普通字符处理
+			if len(s.utf8) == 1 && s.utf8[0] < 0x80 {
+				c := s.utf8[0]
+				s.utf8 = s.utf8[:0]
+				s.feed(c)
+			}
+		}
+		return
+	}
+
+	if b < 0x20 || b == 0x7F {
+		s.control(b)
+		return
+	}
+	s.put(rune(b), false, 1)
+}
+
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+func (s *Screen) control(b byte) {
+	switch b {
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+		s.wrapNext = false
+		s.touch()
+	case '\t':
+		s.col = (s.col/8 + 1) * 8
+		if s.col >= s.opt.Cols {
+			s.col = s.opt.Cols - 1
+		}
+		s.wrapNext = false
+		s.touch()
+	case '\r':
+		s.col, s.wrapNext = 0, false
+		s.touch()
+	case '\n', '\v', '\f':
+		s.lineFeed(!s.opt.NoAutoCR)
+	case 0x1b:
+		s.state = screenEscape
+	}
+}
+
+func (s *Screen) escape(b byte) {
+	s.state = screenGround
+	switch b {
+	case '[':
+		s.state = screenCSI
+	case ']', 'P', 'X', '^', '_':
+		s.state = screenString
+	case '(', ')', '*', '+', '#', '%':
+		s.state = screenEscapeSkip
+	case '7':
+		s.saved = [2]int{s.row, s.col}
+	case '8':
+		s.moveTo(s.saved[0], s.saved[1])
+	case 'D':
+		s.lineFeed(false)
+	case 'E':
+		s.lineFeed(true)
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.emit(len(s.rows))
+		s.reset()
+	}
+}
+
+// put 在光标位置写入一个字符
+func (s *Screen) put(r rune, raw bool, w int) {
+	cols := s.opt.Cols
+	if s.wrapNext || s.col+w > cols {
+		s.rows[s.row].wrapped = true
+		s.rows[s.row].dirty = true
+		s.lineFeed(true)
+		// 换行不输出自动换行的行，当前行仍然是同一行
+		s.rows[s.row].dirty = true
+	}
+	line := s.rows[s.row]
+	// 覆盖宽字符的一半时，清除另一半
+	if line.cells[s.col].wide && s.col > 0 {
+		line.cells[s.col-1] = screenCell{}
+	}
+	if s.col+w < cols && line.cells[s.col+w].wide {
+		line.cells[s.col+w] = screenCell{}
+	}
+	line.cells[s.col] = screenCell{r: r, raw: raw}
+	if w == 2 {
+		line.cells[s.col+1] = screenCell{wide: true}
+	}
+	line.dirty = true
+	if s.col += w; s.col >= cols {
+		s.col, s.wrapNext = cols-1, true
+	}
+	s.touch()
+}
+
+// lineFeed 换行：输出光标所在行及其上方有变化的行，光标在滚动区域最后一行时向上滚动
+func (s *Screen) lineFeed(cr bool) {
+	if !s.rows[s.row].wrapped {
+		// 空行也需要输出
+		s.rows[s.row].dirty = true
+	}
+	s.emit(s.row + 1)
+	switch {
+	case s.row == s.bottom:
+		s.scrollUp(s.top, s.bottom, 1)
+	case s.row < len(s.rows)-1:
+		s.row++
+	}
+	if cr {
+		s.col = 0
+	}
+	s.wrapNext = false
+	s.touch()
+}
+
+func (s *Screen) reverseIndex() {
+	if s.row == s.top {
+		s.scrollDown(s.top, s.bottom, 1)
+	} else if s.row > 0 {
+		s.row--
+	}
+	s.wrapNext = false
+	s.touch()
+}
+
+// moveTo 移动光标，光标向下移动时输出上方有变化的行
+func (s *Screen) moveTo(row, col int) {
+	if row < 0 {
+		row = 0
+	} else if row >= len(s.rows) {
+		row = len(s.rows) - 1
+	}
+	if col < 0 {
+		col = 0
+	} else if col >= s.opt.Cols {
+		col = s.opt.Cols - 1
+	}
+	if row > s.row {
+		s.emit(row)
+	}
+	s.row, s.col, s.wrapNext = row, col, false
+	s.touch()
+}
+
+// scrollUp 滚动区域 [top, bottom] 向上滚动 n 行，滚动出屏幕的行保存到滚动记录中
+func (s *Screen) scrollUp(top, bottom, n int) {
+	for ; n > 0; n-- {
+		line := s.rows[top]
+		if line.dirty {
+			s.out = append(s.out, line.render(-1))
+		}
+		if top == 0 && s.alt == nil && s.opt.Scrollback > 0 {
+			if s.scrollback = append(s.scrollback, line.render(-1)); len(s.scrollback) > s.opt.Scrollback {
+				s.scrollback = s.scrollback[len(s.scrollback)-s.opt.Scrollback:]
+			}
+		}
+		copy(s.rows[top:bottom], s.rows[top+1:bottom+1])
+		s.rows[bottom] = &screenLine{cells: make([]screenCell, s.opt.Cols)}
+	}
+	s.touch()
+}
+
+// scrollDown 滚动区域 [top, bottom] 向下滚动 n 行，滚动出屏幕的行被丢弃
+func (s *Screen) scrollDown(top, bottom, n int) {
+	for ; n > 0; n-- {
+		copy(s.rows[top+1:bottom+1], s.rows[top:bottom])
+		s.rows[top] = &screenLine{cells: make([]screenCell, s.opt.Cols)}
+	}
+	s.touch()
+}
+
+// param 返回第 i 个 CSI 参数，未指定或为 0 时返回 def
+func (s *Screen) param(i, def int) int {
+	p := s.params
+	if len(p) > 0 && (p[0] < '0' || p[0] > ';') {
+		p = p[1:]
+	}
+	for ; i > 0; i-- {
+		idx := bytes.IndexByte(p, ';')
+		if idx < 0 {
+			return def
+		}
+		p = p[idx+1:]
+	}
+	if idx := bytes.IndexAny(p, ";:"); idx >= 0 {
+		p = p[:idx]
+	}
+	if v, err := strconv.Atoi(string(p)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func (s *Screen) csi(final byte) {
+	private := len(s.params) > 0 && s.params[0] >= '<' && s.params[0] <= '?'
+	if private {
+		if (final == 'h' || final == 'l') && s.params[0] == '?' {
+			s.privateMode(final == 'h')
+		}
+		return
+	}
+
+	n := s.param(0, 1)
+	switch final {
+	case 'A':
+		top := 0
+		if s.row >= s.top {
+			top = s.top
+		}
+		row := s.row - n
+		if row < top {
+			row = top
+		}
+		s.moveTo(row, s.col)
+	case 'B', 'e':
+		bottom := len(s.rows) - 1
+		if s.row <= s.bottom {
+			bottom = s.bottom
+		}
+		row := s.row + n
+		if row > bottom {
+			row = bottom
+		}
+		s.moveTo(row, s.col)
+	case 'C', 'a':
+		s.moveTo(s.row, s.col+n)
+	case 'D':
+		s.moveTo(s.row, s.col-n)
+	case 'E':
+		s.moveTo(s.row+n, 0)
+	case 'F':
+		s.moveTo(s.row-n, 0)
+	case 'G', '`':
+		s.moveTo(s.row, n-1)
+	case 'H', 'f':
+		s.moveTo(n-1, s.param(1, 1)-1)
+	case 'd':
+		s.moveTo(n-1, s.col)
+	case 'J':
+		s.eraseDisplay(s.param(0, 0))
+	case 'K':
+		s.eraseLine(s.param(0, 0))
+	case '@':
+		line := s.rows[s.row]
+		if s.col+n > s.opt.Cols {
+			n = s.opt.Cols - s.col
+		}
+		copy(line.cells[s.col+n:], line.cells[s.col:])
+		line.clear(s.col, s.col+n)
+		line.dirty = true
+		s.touch()
+	case 'P':
+		line := s.rows[s.row]
+		if s.col+n > s.opt.Cols {
+			n = s.opt.Cols - s.col
+		}
+		copy(line.cells[s.col:], line.cells[s.col+n:])
+		line.clear(s.opt.Cols-n, s.opt.Cols)
+		line.dirty = line.dirty || !line.isBlank()
+		s.touch()
+	case 'X':
+		line := s.rows[s.row]
+		line.clear(s.col, s.col+n)
+		line.dirty = line.dirty && !line.isBlank()
+		s.touch()
+	case 'L':
+		if s.row >= s.top && s.row <= s.bottom {
+			s.scrollDown(s.row, s.bottom, min(n, s.bottom-s.row+1))
+			s.col = 0
+		}
+	case 'M':
+		if s.row >= s.top && s.row <= s.bottom {
+			for i := 0; i < n && i <= s.bottom-s.row; i++ {
+				copy(s.rows[s.row:s.bottom], s.rows[s.row+1:s.bottom+1])
+				s.rows[s.bottom] = &screenLine{cells: make([]screenCell, s.opt.Cols)}
+			}
+			s.col = 0
+			s.touch()
+		}
+	case 'S':
+		s.scrollUp(s.top, s.bottom, min(n, s.bottom-s.top+1))
+	case 'T':
+		s.scrollDown(s.top, s.bottom, min(n, s.bottom-s.top+1))
+	case 'r':
+		top, bottom := s.param(0, 1)-1, s.param(1, len(s.rows))-1
+		if bottom >= len(s.rows) {
+			bottom = len(s.rows) - 1
+		}
+		if top < bottom {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saved = [2]int{s.row, s.col}
+	case 'u':
+		s.moveTo(s.saved[0], s.saved[1])
+	}
+}
+
+// privateMode 处理 DEC 私有模式，目前只处理备用屏幕（如 top、vi 等全屏程序）
+func (s *Screen) privateMode(set bool) {
+	switch s.param(0, 0) {
+	case 47, 1047, 1049:
+		if set && s.alt == nil {
+			s.emit(s.row)
+			s.saved = [2]int{s.row, s.col}
+			s.alt, s.rows = s.rows, s.newRows(len(s.rows))
+			s.touch()
+		} else if !set && s.alt != nil {
+			// 备用屏幕上未输出的内容直接丢弃，与终端的显示效果一致
+			s.rows, s.alt = s.alt, nil
+			for _, line := range s.rows {
+				line.dirty = false
+			}
+			s.row, s.col, s.wrapNext = s.saved[0], s.saved[1], false
+			s.touch()
+		}
+	}
+}
+
+// eraseDisplay 清除屏幕：0 光标到屏幕末尾、1 屏幕开头到光标、2 整个屏幕、3 整个屏幕以及滚动记录
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for _, line := range s.rows[s.row+1:] {
+			line.clear(0, s.opt.Cols)
+			line.wrapped, line.dirty = false, false
+		}
+	case 1:
+		s.eraseLine(1)
+		for _, line := range s.rows[:s.row] {
+			line.clear(0, s.opt.Cols)
+			line.wrapped, line.dirty = false, false
+		}
+	case 2, 3:
+		for _, line := range s.rows {
+			line.clear(0, s.opt.Cols)
+			line.wrapped, line.dirty = false, false
+		}
+		if mode == 3 {
+			s.scrollback = nil
+		}
+	}
+	s.touch()
+}
+
+// eraseLine 清除光标所在行：0 光标到行尾、1 行首到光标、2 整行
+func (s *Screen) eraseLine(mode int) {
+	line := s.rows[s.row]
+	switch mode {
+	case 0:
+		line.clear(s.col, s.opt.Cols)
+		line.wrapped = false
+	case 1:
+		line.clear(0, s.col+1)
+	case 2:
+		line.clear(0, s.opt.Cols)
+		line.wrapped = false
+	}
+	line.dirty = line.dirty && !line.isBlank()
+	s.wrapNext = false
+	s.touch()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var screenArr = [][]string{
+	{"abc", "abc"},
+	{"abc\r\ndef\r\n", "abc\ndef\n"},
+	{"abc\ndef", "abc\ndef"},
+	{"\r\n\r\nabc", "\n\nabc"},
+	{"abcc\b", "abcc"},
+	{"abcc\b \b", "abc"},
+	{"abc\x1b[1D\x1b[K", "ab"},
+	{"abc\rx", "xbc"},
+	{"10%\r20%\r30%\r\n", "30%\n"},
+	{"\x1b[31mred\x1b[0m", "red"},
+	{"\x1b]0;title\x07abc", "abc"},
+	{"\x1b]0;title\x1b\\abc", "abc"},
+	{"a\tb", "a       b"},
+	{"abcdef\x1b[3D\x1b[1P", "abcef"},
+	{"abcdef\x1b[3D\x1b[2@", "abc  def"},
+	{"abcdef\x1b[3D\x1b[2X", "abc  f"},
+	{"line1\r\nline2\x1b[1;1H\x1b[2K", "line1\n"},
+	{"line1\r\nline2\x1b[1;1Hxxx\x1b[3;1H", "line1\nxxxe1\nline2\n"},
+	{"中文abc", "中文abc"},
+	{"中文\x1b[2D文", "中文"},
+	{"\xc4\xe3\xba\xc3\r\n", "\xc4\xe3\xba\xc3\n"},
+	{"CD_DA11F_MX01#\x1b[1;1H\x1b[1;16H", "CD_DA11F_MX01# "},
+	{"\x1b[?1049hfull screen\x1b[?1049labc", "abc"},
+	{"\u001B[1;13r\u001B[1;1H\u001B[80;1HPress any key to continue\n\u001B[13;1H\u001B[?25h\u001B[80;27H\u001B[?6l\u001B[1;80r\u001B[?7h\u001B[2J\u001B[1;1H\u001B[1920;1920H\u001B[6n\u001B[1;1HYour previous successful login (as manager) was on 2022-08-25 01:30:16 from 172.26.66.11 \u001B[1;80r\u001B[80;1H\u001B[80;1H\u001B[2K\u001B[80;1H\u001B[?25h\u001B[80;1H\u001B[80;1HCD_OA_11F_MX01#\u001B[80;1H\u001B[80;17H\u001B[80;1H\u001B[?25h\u001B[80;17H", "Press any key to continue\nYour previous successful login (as manager) was on 2022-08-25 01:30:16 from 172.26.66.11\nCD_OA_11F_MX01# "},
+}
+
+// screenDo 返回新的完整的行以及当前行
+func screenDo(s *Screen, src string) string {
+	return string(append(s.Write([]byte(src)), s.Pending()...))
+}
+
+func TestScreen(t *testing.T) {
+	for _, val := range screenArr {
+		assert.Equal(t, val[1], screenDo(NewScreen(), val[0]), "%q", val[0])
+	}
+}
+
+func TestScreen_Chunked(t *testing.T) {
+	// 逐个字节写入
+	for _, val := range screenArr {
+		s := NewScreen()
+		var dst []byte
+		for i := 0; i < len(val[0]); i++ {
+			dst = append(dst, s.Write([]byte{val[0][i]})...)
+		}
+		assert.Equal(t, val[1], string(append(dst, s.Pending()...)), "%q", val[0])
+	}
+}
+
+func TestScreen_Wrap(t *testing.T) {
+	s := NewScreen(ScreenOptions{Rows: 5, Cols: 4})
+	assert.Equal(t, "abcdefghij", screenDo(s, "abcdefghij"))
+	assert.Equal(t, "abcdefghij\n", screenDo(s, "\r\n"))
+	assert.Equal(t, []string{"abcd", "efgh", "ij", ""}, s.Display())
+
+	// 宽字符放不下时换到下一行
+	s = NewScreen(ScreenOptions{Rows: 5, Cols: 3})
+	assert.Equal(t, "a中文\n", screenDo(s, "a中文\r\n"))
+	assert.Equal(t, []string{"a中", "文", ""}, s.Display())
+}
+
+func TestScreen_Scroll(t *testing.T) {
+	s := NewScreen(ScreenOptions{Rows: 3, Cols: 10})
+	assert.Equal(t, "1\n2\n3\n4\n5", screenDo(s, "1\r\n2\r\n3\r\n4\r\n5"))
+	assert.Equal(t, []string{"3", "4", "5"}, s.Display())
+	assert.Equal(t, []string{"1", "2"}, s.Scrollback())
+
+	// 滚动区域：第 1 行固定，第 2~3 行滚动
+	s = NewScreen(ScreenOptions{Rows: 3, Cols: 10})
+	assert.Equal(t, "head\na\nb\nc", screenDo(s, "head\x1b[2;3r\x1b[2;1Ha\r\nb\r\nc"))
+	assert.Equal(t, []string{"head", "b", "c"}, s.Display())
+	assert.Empty(t, s.Scrollback())
+
+	// 反向换行
+	s = NewScreen(ScreenOptions{Rows: 3, Cols: 10})
+	screenDo(s, "a\r\nb\x1b[1;1H\x1bMx")
+	assert.Equal(t, []string{"x", "a", "b"}, s.Display())
+}
+
+func TestScreen_Redraw(t *testing.T) {
+	// 类似 top 的刷新：回到左上角重绘
+	s := NewScreen(ScreenOptions{Rows: 5, Cols: 20})
+	assert.Equal(t, "cpu 10%\nmem 20%\n", screenDo(s, "\x1b[H\x1b[2Jcpu 10%\r\nmem 20%\r\n"))
+	assert.Equal(t, "cpu 11%\nmem 20%\n", screenDo(s, "\x1b[H\x1b[2Jcpu 11%\r\nmem 20%\r\n"))
+	// 只修改第一行
+	assert.Equal(t, "cpu 12%\n", screenDo(s, "\x1b[1;5H12\x1b[3;1H"))
+	assert.Equal(t, []string{"cpu 12%", "mem 20%", ""}, s.Display())
+	row, col := s.Cursor()
+	assert.Equal(t, 2, row)
+	assert.Equal(t, 0, col)
+
+	s.Reset()
+	assert.Equal(t, []string{""}, s.Display())
+}
+
+func TestScreen_Flush(t *testing.T) {
+	s := NewScreen()
+	assert.Empty(t, s.Write([]byte("SW1# \x1b[0m")))
+	assert.Equal(t, "SW1# ", string(s.Pending()))
+	assert.Equal(t, "SW1# ", string(s.Flush()))
+
+	// Flush 之后颜色、标题等不改变屏幕内容，当前行仍然视为已输出
+	assert.Equal(t, "", screenDo(s, "\x1b[0m\x1b]0;title\x07\x07"))
+	assert.Equal(t, "SW1# show", screenDo(s, "show"))
+	assert.Equal(t, "SW1# show version\n", screenDo(s, " version\r\n"))
+}
+
+func BenchmarkScreen(b *testing.B) {
+	s := NewScreen()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, val := range screenArr {
+			screenDo(s, val[0])
+		}
+	}
+}
@@ -1,5 +1,25 @@
 package filter
 
+// UTF8Replace 删除UTF8替换字符（U+FFFD，通常是解码失败时产生的）
+func UTF8Replace() IFilter {
+	return &lineFilter{
+		do: func(s []byte, _ []byte) []byte {
+			dst := append(make([]byte, 0, len(s)), s...)
+			var dropArr [][2]int
+			var drop [2]int
+			var found bool
+			for pos := 0; pos < len(dst); {
+				if found, pos, drop = checkUTF8ReplaceChar(dst, pos); found {
+					dropArr = append(dropArr, drop)
+				} else {
+					pos++
+				}
+			}
+			return dropMultiBytes(dst, dropArr)
+		},
+	}
+}
+
 // checkUTF8ReplaceChar 检查UTF8替换字符 0xEF 0XBF 0XBD
 func checkUTF8ReplaceChar(s []byte, pos int) (bool, int, [2]int) {
 	var cnt int
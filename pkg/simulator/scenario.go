@@ -0,0 +1,232 @@
+package simulator
+
+import (
+	"fmt"
+	"github.com/3th1nk/easyshell/pkg/fake"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Scenario 模拟设备的场景，可以从 YAML、JSON 文件加载，参考 testdata/device.yaml
+type Scenario struct {
+	Banner     string    `yaml:"banner" json:"banner"`         // 连接建立后输出的欢迎信息
+	Prompt     string    `yaml:"prompt" json:"prompt"`         // 命令行提示符，默认值 "device# "
+	MorePrompt string    `yaml:"morePrompt" json:"morePrompt"` // 分页提示符，默认值 "  ---- More ----"
+	NoEcho     bool      `yaml:"noEcho" json:"noEcho"`         // 不回显输入的命令
+	Unknown    string    `yaml:"unknown" json:"unknown"`       // 没有匹配的命令时的输出，默认值 "% Unknown command: <cmd>"
+	Login      Login     `yaml:"login" json:"login"`           // 登录
+	Commands   []Command `yaml:"commands" json:"commands"`     // 命令，按顺序匹配
+}
+
+// Login 登录的配置，未指定 User 和 Password 时不需要登录
+//
+//	TELNET 在连接建立后依次提示输入用户名、密码；SSH 通过协议认证（password、keyboard-interactive）
+type Login struct {
+	User           string   `yaml:"user" json:"user"`
+	Password       string   `yaml:"password" json:"password"`
+	UserPrompt     string   `yaml:"userPrompt" json:"userPrompt"`         // 用户名提示符，默认值 "Username: "
+	PasswordPrompt string   `yaml:"passwordPrompt" json:"passwordPrompt"` // 密码提示符，默认值 "Password: "
+	FailMessage    string   `yaml:"failMessage" json:"failMessage"`       // 登录失败的提示，默认值 "% Authentication failed"
+	MaxAttempts    int      `yaml:"maxAttempts" json:"maxAttempts"`       // 最多尝试次数，超过后断开连接，默认值 3
+	Expired        *Confirm `yaml:"expired" json:"expired"`               // 登录后提示密码已过期（TELNET、SSH 都有效），如 "Change now? [Y/N]: "
+}
+
+// Command 命令的配置
+type Command struct {
+	Command    string        `yaml:"command" json:"command"`       // 命令，去掉前后空格后完全相同时匹配
+	Regex      string        `yaml:"regex" json:"regex"`           // 匹配命令的正则表达式，Command 为空时有效
+	Delay      time.Duration `yaml:"delay" json:"delay"`           // 输出前的延迟，如 "500ms"
+	Output     string        `yaml:"output" json:"output"`         // 输出的内容
+	Pages      []string      `yaml:"pages" json:"pages"`           // 分页输出的内容，在 Output 之后输出
+	Confirm    *Confirm      `yaml:"confirm" json:"confirm"`       // 输出之后要求确认
+	Prompt     string        `yaml:"prompt" json:"prompt"`         // 命令执行后切换的提示符，如 configure terminal 后切换为 "device(config)# "
+	Disconnect bool          `yaml:"disconnect" json:"disconnect"` // 命令执行后断开连接，如 exit、quit
+	re         *regexp.Regexp
+}
+
+// Confirm 要求确认的配置，输入 y 或 yes（不区分大小写）时认为确认
+type Confirm struct {
+	Question string `yaml:"question" json:"question"` // 提示内容，如 "Proceed with reload? [y/n]: "
+	Yes      string `yaml:"yes" json:"yes"`           // 确认后的输出
+	No       string `yaml:"no" json:"no"`             // 取消后的输出
+}
+
+// LoadScenario 从 YAML、JSON 文件加载场景
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario 解析 YAML、JSON 格式的场景
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	if err := sc.init(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) init() error {
+	if sc.Prompt == "" {
+		sc.Prompt = "device# "
+	}
+	if sc.Login.UserPrompt == "" {
+		sc.Login.UserPrompt = "Username: "
+	}
+	if sc.Login.PasswordPrompt == "" {
+		sc.Login.PasswordPrompt = "Password: "
+	}
+	if sc.Login.FailMessage == "" {
+		sc.Login.FailMessage = "% Authentication failed"
+	}
+	if sc.Login.MaxAttempts <= 0 {
+		sc.Login.MaxAttempts = 3
+	}
+	for i := range sc.Commands {
+		c := &sc.Commands[i]
+		if c.Command == "" && c.Regex != "" {
+			re, err := regexp.Compile(c.Regex)
+			if err != nil {
+				return fmt.Errorf("commands[%d]: %v", i, err)
+			}
+			c.re = re
+		}
+	}
+	return nil
+}
+
+func (sc *Scenario) needLogin() bool {
+	return sc.Login.User != "" || sc.Login.Password != ""
+}
+
+// newTransport 创建模拟设备的命令行，login 为 true 时在命令行中登录（TELNET）
+func (sc *Scenario) newTransport(login bool) *fake.Transport {
+	cfg := fake.Config{
+		Banner:     sc.Banner,
+		Prompt:     sc.Prompt,
+		MorePrompt: sc.MorePrompt,
+		NoEcho:     sc.NoEcho,
+	}
+	if sc.Unknown != "" {
+		cfg.Unknown = func(cmd string) []fake.Step {
+			return []fake.Step{fake.Output(withLF(sc.Unknown))}
+		}
+	}
+	if login && sc.needLogin() {
+		cfg.Before = append(cfg.Before, sc.loginStep())
+	}
+	if sc.Login.Expired != nil {
+		cfg.Before = append(cfg.Before, sc.Login.Expired.step())
+	}
+
+	t := fake.New(cfg)
+	for i := range sc.Commands {
+		c := &sc.Commands[i]
+		switch {
+		case c.Command != "":
+			t.Handle(c.Command, c.steps()...)
+		case c.re != nil:
+			t.HandleRegex(c.re, c.steps()...)
+		}
+	}
+	return t
+}
+
+func (c *Command) steps() []fake.Step {
+	steps := []fake.Step{}
+	if c.Delay > 0 {
+		steps = append(steps, fake.Delay(c.Delay))
+	}
+	if c.Output != "" {
+		steps = append(steps, fake.Output(withLF(c.Output)))
+	}
+	if len(c.Pages) != 0 {
+		pages := make([]string, len(c.Pages))
+		for i, page := range c.Pages {
+			pages[i] = withLF(page)
+		}
+		steps = append(steps, fake.More(pages...))
+	}
+	if c.Confirm != nil {
+		steps = append(steps, c.Confirm.step())
+	}
+	if c.Prompt != "" {
+		steps = append(steps, fake.SetPrompt(c.Prompt))
+	}
+	if c.Disconnect {
+		steps = append(steps, fake.Disconnect())
+	}
+	return steps
+}
+
+func (c *Confirm) step() fake.Step {
+	return fake.Ask(c.Question, func(input string) []fake.Step {
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			if c.Yes != "" {
+				return []fake.Step{fake.Output(withLF(c.Yes))}
+			}
+		default:
+			if c.No != "" {
+				return []fake.Step{fake.Output(withLF(c.No))}
+			}
+		}
+		return nil
+	})
+}
+
+// loginStep 提示输入用户名、密码，失败次数超过 MaxAttempts 后断开连接
+func (sc *Scenario) loginStep() fake.Step {
+	l := sc.Login
+	return func(t *fake.Transport) error {
+		for i := 0; i < l.MaxAttempts; i++ {
+			var user string
+			if l.User != "" {
+				if err := t.Send(l.UserPrompt); err != nil {
+					return err
+				}
+				var err error
+				if user, err = t.ReadLine(); err != nil {
+					return err
+				}
+				// 回显用户名
+				if err = t.Send(user + "\n"); err != nil {
+					return err
+				}
+			}
+			if err := t.Send(l.PasswordPrompt); err != nil {
+				return err
+			}
+			password, err := t.ReadLine()
+			if err != nil {
+				return err
+			}
+			if err = t.Send("\n"); err != nil {
+				return err
+			}
+			if user == l.User && password == l.Password {
+				return nil
+			}
+			if err = t.Send(withLF(l.FailMessage)); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("authentication failed")
+	}
+}
+
+func withLF(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
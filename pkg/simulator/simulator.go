@@ -0,0 +1,62 @@
+package simulator
+
+import (
+	"github.com/3th1nk/easyshell/pkg/fake"
+	"io"
+	"net"
+	"sync"
+)
+
+// sessions 记录各个会话的模拟命令行
+type sessions struct {
+	mu         sync.Mutex
+	transports []*fake.Transport
+}
+
+func (s *sessions) add(t *fake.Transport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transports = append(s.transports, t)
+}
+
+// Inputs 返回所有会话中读取到的输入（每行一项），包括命令、交互的回答以及 TELNET 登录时输入的用户名、密码
+func (s *sessions) Inputs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var inputs []string
+	for _, t := range s.transports {
+		inputs = append(inputs, t.Inputs()...)
+	}
+	return inputs
+}
+
+// Disconnect 断开所有会话，但不停止监听，用于测试连接断开后的自动重连
+func (s *sessions) Disconnect() {
+	s.closeAll()
+}
+
+func (s *sessions) closeAll() {
+	s.mu.Lock()
+	transports := s.transports
+	s.mu.Unlock()
+	for _, t := range transports {
+		_ = t.Close()
+	}
+}
+
+// serveTransport 在连接 rw 上运行模拟的命令行，命令行结束（如断开连接）或连接关闭时返回
+func serveTransport(rw io.ReadWriter, t *fake.Transport) {
+	go func() {
+		_, _ = io.Copy(t, rw)
+		_ = t.CloseInput()
+	}()
+	_, _ = io.Copy(rw, t)
+	_ = t.Close()
+}
+
+func hostPort(addr net.Addr) (string, int) {
+	if v, ok := addr.(*net.TCPAddr); ok {
+		return v.IP.String(), v.Port
+	}
+	return addr.String(), 0
+}
@@ -0,0 +1,120 @@
+package simulator
+
+import (
+	"github.com/3th1nk/easyshell/pkg/telnet"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScenario(t *testing.T) {
+	sc, err := LoadScenario("testdata/device.yaml")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "SW1# ", sc.Prompt)
+		assert.Equal(t, "admin", sc.Login.User)
+		assert.Equal(t, "Username: ", sc.Login.UserPrompt)
+		assert.Equal(t, 3, sc.Login.MaxAttempts)
+		assert.NotNil(t, sc.Login.Expired)
+		assert.Len(t, sc.Commands[2].Pages, 3)
+		assert.Equal(t, 300*time.Millisecond, sc.Commands[6].Delay)
+		assert.NotNil(t, sc.Commands[4].re)
+	}
+
+	sc, err = ParseScenario([]byte(`{"prompt": "R1>", "commands": [{"command": "show clock", "output": "12:00", "delay": "10ms"}]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "R1>", sc.Prompt)
+		assert.Equal(t, 10*time.Millisecond, sc.Commands[0].Delay)
+		assert.False(t, sc.needLogin())
+	}
+
+	_, err = ParseScenario([]byte(`commands: [{regex: "("}]`))
+	assert.Error(t, err)
+}
+
+func newTestTelnetServer(t *testing.T) *TelnetServer {
+	sc, err := LoadScenario("testdata/device.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewTelnetServer("127.0.0.1:0", sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestTelnetServer_Login(t *testing.T) {
+	s := newTestTelnetServer(t)
+
+	c, err := telnet.NewClient(&telnet.ClientConfig{Addr: s.Addr(), User: "admin", Password: "secret", Timeout: 5 * time.Second})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+	assert.Contains(t, c.Welcome(), "Welcome to SW1 (simulator)")
+
+	_, err = c.Write([]byte("show version\n"))
+	assert.NoError(t, err)
+	data, _, err := c.ReadUtil2("Uptime is 10 days")
+	assert.NoError(t, err)
+	assert.Contains(t, data.String(), "Software Version 1.2.3")
+	assert.Equal(t, []string{"admin", "secret"}, s.Inputs()[:2])
+	assert.Contains(t, s.Inputs(), "show version")
+}
+
+func TestTelnetServer_LoginFailed(t *testing.T) {
+	s := newTestTelnetServer(t)
+
+	_, err := telnet.NewClient(&telnet.ClientConfig{Addr: s.Addr(), User: "admin", Password: "wrong", Timeout: 5 * time.Second})
+	assert.EqualError(t, err, "invalid username or password")
+}
+
+func TestSshServer(t *testing.T) {
+	sc, err := LoadScenario("testdata/device.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSshServer("127.0.0.1:0", sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, auth := range []ssh.AuthMethod{
+		ssh.Password("secret"),
+		ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			return []string{"secret"}, nil
+		}),
+	} {
+		client, err := ssh.Dial("tcp", s.Addr(), &ssh.ClientConfig{
+			User:            "admin",
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: ssh.FixedHostKey(s.HostKey()),
+		})
+		if !assert.NoError(t, err) {
+			continue
+		}
+		session, err := client.NewSession()
+		if assert.NoError(t, err) {
+			var out strings.Builder
+			session.Stdout = &out
+			session.Stdin = strings.NewReader("N\nshow version\nquit\n")
+			assert.NoError(t, session.Shell())
+			assert.NoError(t, session.Wait())
+			assert.Contains(t, out.String(), "Change now? [Y/N]: \r\nPassword not changed.\r\nSW1# ")
+			assert.Contains(t, out.String(), "Software Version 1.2.3\r\n")
+			assert.True(t, strings.HasSuffix(out.String(), "quit\r\nBye\r\n"))
+		}
+		_ = client.Close()
+	}
+
+	_, err = ssh.Dial("tcp", s.Addr(), &ssh.ClientConfig{
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+package simulator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"sync"
+)
+
+// SshServer 模拟设备的 SSH 服务端，支持 password、keyboard-interactive 认证以及交互式 shell
+type SshServer struct {
+	sessions
+	ln     net.Listener
+	config *ssh.ServerConfig
+	signer ssh.Signer
+	sc     *Scenario
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	wg     sync.WaitGroup
+}
+
+// NewSshServer 监听指定的地址（如 "127.0.0.1:0" 表示随机端口），每个 shell 会话按场景模拟一个设备，主机密钥随机生成
+func NewSshServer(addr string, sc *Scenario) (*SshServer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SshServer{signer: signer, sc: sc, conns: map[net.Conn]struct{}{}}
+	s.config = &ssh.ServerConfig{NoClientAuth: !sc.needLogin()}
+	if sc.needLogin() {
+		check := func(conn ssh.ConnMetadata, password string) (*ssh.Permissions, error) {
+			if conn.User() == sc.Login.User && password == sc.Login.Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", conn.User())
+		}
+		s.config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return check(conn, string(password))
+		}
+		s.config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client("", "", []string{sc.Login.PasswordPrompt}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) != 1 {
+				return nil, fmt.Errorf("unexpected answers")
+			}
+			return check(conn, answers[0])
+		}
+	}
+	s.config.AddHostKey(signer)
+
+	if s.ln, err = net.Listen("tcp", addr); err != nil {
+		return nil, err
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *SshServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *SshServer) Host() string {
+	host, _ := hostPort(s.ln.Addr())
+	return host
+}
+
+func (s *SshServer) Port() int {
+	_, port := hostPort(s.ln.Addr())
+	return port
+}
+
+// HostKey 服务端的主机公钥
+func (s *SshServer) HostKey() ssh.PublicKey {
+	return s.signer.PublicKey()
+}
+
+// Close 停止监听，并关闭所有连接
+func (s *SshServer) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.closeAll()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SshServer) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(c)
+			_ = c.Close()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *SshServer) handleConn(c net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(c, s.config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		wg.Add(1)
+		go func(newCh ssh.NewChannel) {
+			defer wg.Done()
+			s.handleSession(newCh)
+		}(newCh)
+	}
+}
+
+// handleSession 处理会话请求，只支持交互式 shell
+func (s *SshServer) handleSession(newCh ssh.NewChannel) {
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+
+	done := make(chan struct{})
+	var started bool
+	for {
+		select {
+		case <-done:
+			return
+		case req, ok := <-reqs:
+			if !ok {
+				return
+			}
+			switch req.Type {
+			case "pty-req", "window-change", "env":
+				_ = req.Reply(true, nil)
+			case "shell":
+				if started {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				started = true
+				_ = req.Reply(true, nil)
+				t := s.sc.newTransport(false)
+				s.add(t)
+				go func() {
+					defer close(done)
+					serveTransport(ch, t)
+					_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				}()
+			default:
+				if req.WantReply {
+					_ = req.Reply(false, nil)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package simulator
+
+import (
+	"github.com/3th1nk/easyshell/pkg/telnet"
+)
+
+// TelnetServer 模拟设备的 TELNET 服务端
+type TelnetServer struct {
+	sessions
+	srv *telnet.Server
+	sc  *Scenario
+}
+
+// NewTelnetServer 监听指定的地址（如 "127.0.0.1:0" 表示随机端口），每个连接按场景模拟一个设备
+func NewTelnetServer(addr string, sc *Scenario) (*TelnetServer, error) {
+	s := &TelnetServer{sc: sc}
+	srv, err := telnet.NewServer(addr, func(c *telnet.ServerConn) {
+		t := sc.newTransport(true)
+		s.add(t)
+		serveTransport(c, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.srv = srv
+	return s, nil
+}
+
+func (s *TelnetServer) Addr() string {
+	return s.srv.Addr().String()
+}
+
+func (s *TelnetServer) Host() string {
+	host, _ := hostPort(s.srv.Addr())
+	return host
+}
+
+func (s *TelnetServer) Port() int {
+	_, port := hostPort(s.srv.Addr())
+	return port
+}
+
+// Close 停止监听，并关闭所有连接
+func (s *TelnetServer) Close() error {
+	s.closeAll()
+	return s.srv.Close()
+}
@@ -0,0 +1,141 @@
+package style
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Renderer 将带样式的一行文本转换为字符串
+type Renderer interface {
+	Render(line Line) string
+}
+
+// RenderFunc 使用函数实现 Renderer
+type RenderFunc func(line Line) string
+
+func (f RenderFunc) Render(line Line) string {
+	return f(line)
+}
+
+// Plain 输出不带样式的文本
+func Plain() Renderer {
+	return RenderFunc(Line.Text)
+}
+
+// HTMLOptions HTML 渲染的配置
+type HTMLOptions struct {
+	// 不为空时使用 CSS class 表示字体属性和调色板中的颜色（如 prefix+"bold"、prefix+"fg-1"、prefix+"bg-208"），24 位真彩色仍然使用内联样式；
+	// 为空时全部使用内联样式
+	ClassPrefix string
+	// 默认的前景色、背景色，仅用于反显（Reverse），默认值 #000000、#ffffff
+	Foreground, Background string
+}
+
+// HTML 输出 HTML，每一段有样式的文本使用一个 <span>，没有样式的文本直接输出，文本中的特殊字符会被转义
+func HTML(opt ...HTMLOptions) Renderer {
+	h := &htmlRenderer{}
+	if len(opt) > 0 {
+		h.opt = opt[0]
+	}
+	if h.opt.Foreground == "" {
+		h.opt.Foreground = "#000000"
+	}
+	if h.opt.Background == "" {
+		h.opt.Background = "#ffffff"
+	}
+	return h
+}
+
+type htmlRenderer struct {
+	opt HTMLOptions
+}
+
+func (h *htmlRenderer) Render(line Line) string {
+	var sb strings.Builder
+	for _, span := range line {
+		if span.Style.IsDefault() {
+			sb.WriteString(html.EscapeString(span.Text))
+			continue
+		}
+		class, style := h.attrs(span.Style)
+		sb.WriteString("<span")
+		if class != "" {
+			sb.WriteString(` class="`)
+			sb.WriteString(class)
+			sb.WriteString(`"`)
+		}
+		if style != "" {
+			sb.WriteString(` style="`)
+			sb.WriteString(style)
+			sb.WriteString(`"`)
+		}
+		sb.WriteString(">")
+		sb.WriteString(html.EscapeString(span.Text))
+		sb.WriteString("</span>")
+	}
+	return sb.String()
+}
+
+// attrs 返回样式对应的 class 和内联样式
+func (h *htmlRenderer) attrs(s Style) (string, string) {
+	var classes, styles []string
+
+	fg, bg := s.Fg, s.Bg
+	var fgDefault, bgDefault string // 反显时默认颜色对应的值
+	if s.Has(Reverse) {
+		fg, bg = bg, fg
+		fgDefault, bgDefault = h.opt.Background, h.opt.Foreground
+	}
+	for _, v := range []struct {
+		name, css, def string
+		c              Color
+	}{
+		{"fg", "color", fgDefault, fg},
+		{"bg", "background-color", bgDefault, bg},
+	} {
+		switch {
+		case v.c.Mode == ColorIndex && h.opt.ClassPrefix != "":
+			classes = append(classes, h.opt.ClassPrefix+v.name+"-"+strconv.Itoa(int(v.c.Index)))
+		case !v.c.IsDefault():
+			styles = append(styles, v.css+":"+v.c.Hex())
+		case v.def != "":
+			styles = append(styles, v.css+":"+v.def)
+		}
+	}
+
+	for _, v := range []struct {
+		attr       Attr
+		class, css string
+	}{
+		{Bold, "bold", "font-weight:bold"},
+		{Dim, "dim", "opacity:0.5"},
+		{Italic, "italic", "font-style:italic"},
+		{Underline, "underline", ""},
+		{Strike, "strike", ""},
+		{Blink, "blink", ""},
+		{Reverse, "reverse", ""},
+		{Hidden, "hidden", "visibility:hidden"},
+	} {
+		if !s.Has(v.attr) {
+			continue
+		}
+		if h.opt.ClassPrefix != "" {
+			classes = append(classes, h.opt.ClassPrefix+v.class)
+		} else if v.css != "" {
+			styles = append(styles, v.css)
+		}
+	}
+	// 下划线和删除线使用同一个 CSS 属性
+	if h.opt.ClassPrefix == "" {
+		switch {
+		case s.Has(Underline) && s.Has(Strike):
+			styles = append(styles, "text-decoration:underline line-through")
+		case s.Has(Underline):
+			styles = append(styles, "text-decoration:underline")
+		case s.Has(Strike):
+			styles = append(styles, "text-decoration:line-through")
+		}
+	}
+	return strings.Join(classes, " "), strings.Join(styles, ";")
+}
@@ -0,0 +1,360 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ColorMode 颜色的类型
+type ColorMode uint8
+
+const (
+	ColorDefault ColorMode = iota // 终端默认颜色
+	ColorIndex                    // 调色板中的颜色：0~7 标准颜色、8~15 高亮颜色、16~231 6x6x6 色块、232~255 灰度
+	ColorRGB                      // 24 位真彩色
+)
+
+// Color 前景色或背景色
+type Color struct {
+	Mode    ColorMode
+	Index   uint8 // Mode 为 ColorIndex 时有效
+	R, G, B uint8 // Mode 为 ColorRGB 时有效
+}
+
+// Indexed 调色板中的颜色
+func Indexed(i uint8) Color {
+	return Color{Mode: ColorIndex, Index: i}
+}
+
+// RGB 24 位真彩色
+func RGB(r, g, b uint8) Color {
+	return Color{Mode: ColorRGB, R: r, G: g, B: b}
+}
+
+// IsDefault 是否为终端默认颜色
+func (c Color) IsDefault() bool {
+	return c.Mode == ColorDefault
+}
+
+// RGB 返回颜色的 RGB 值，调色板中的颜色按 xterm 的默认调色板转换，默认颜色返回 false
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	switch c.Mode {
+	case ColorRGB:
+		return c.R, c.G, c.B, true
+	case ColorIndex:
+		switch i := int(c.Index); {
+		case i < 16:
+			v := xtermColors[i]
+			return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+		case i < 232:
+			i -= 16
+			return cubeLevels[i/36], cubeLevels[i/6%6], cubeLevels[i%6], true
+		default:
+			v := uint8(8 + (i-232)*10)
+			return v, v, v, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// Hex 返回 #rrggbb 格式的颜色，默认颜色返回空
+func (c Color) Hex() string {
+	r, g, b, ok := c.RGB()
+	if !ok {
+		return ""
+	}
+	const digits = "0123456789abcdef"
+	return string([]byte{'#', digits[r>>4], digits[r&0xF], digits[g>>4], digits[g&0xF], digits[b>>4], digits[b&0xF]})
+}
+
+// xterm 默认调色板中的 16 种标准颜色和高亮颜色
+var xtermColors = [16]uint32{
+	0x000000, 0xcd0000, 0x00cd00, 0xcdcd00, 0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+	0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00, 0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+}
+
+// 6x6x6 色块中每个分量的取值
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// Attr 字体属性
+type Attr uint16
+
+const (
+	Bold Attr = 1 << iota
+	Dim
+	Italic
+	Underline
+	Blink
+	Reverse
+	Hidden
+	Strike
+)
+
+// Style 文本的样式
+type Style struct {
+	Fg, Bg Color
+	Attr   Attr
+}
+
+// Has 是否包含指定的字体属性
+func (s Style) Has(attr Attr) bool {
+	return s.Attr&attr != 0
+}
+
+// IsDefault 是否为默认样式（没有颜色和字体属性）
+func (s Style) IsDefault() bool {
+	return s == Style{}
+}
+
+// Span 样式相同的一段文本
+type Span struct {
+	Text  string
+	Style Style
+}
+
+// Line 带样式的一行文本
+type Line []Span
+
+// Text 返回不带样式的文本
+func (l Line) Text() string {
+	switch len(l) {
+	case 0:
+		return ""
+	case 1:
+		return l[0].Text
+	}
+	var sb strings.Builder
+	for _, span := range l {
+		sb.WriteString(span.Text)
+	}
+	return sb.String()
+}
+
+// Parser 解析 SGR（Select Graphic Rendition）控制序列，将文本拆分为样式相同的多段，其他控制序列被删除
+//
+//	样式在多行之间延续（如颜色设置之后跨越多行才重置），因此每个输出流需要使用单独的 Parser
+type Parser struct {
+	style Style
+}
+
+// Style 返回当前的样式
+func (p *Parser) Style() Style {
+	return p.style
+}
+
+// Reset 恢复默认样式
+func (p *Parser) Reset() {
+	p.style = Style{}
+}
+
+// Parse 解析一行文本
+func (p *Parser) Parse(s string) Line {
+	var line Line
+	var text strings.Builder
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		if n := len(line); n > 0 && line[n-1].Style == p.style {
+			line[n-1].Text += text.String()
+		} else {
+			line = append(line, Span{Text: text.String(), Style: p.style})
+		}
+		text.Reset()
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '\x1b' {
+			j := strings.IndexByte(s[i:], '\x1b')
+			if j < 0 {
+				j = len(s) - i
+			}
+			text.WriteString(s[i : i+j])
+			i += j
+			continue
+		}
+
+		// 控制序列
+		if i+1 >= len(s) {
+			break
+		}
+		switch s[i+1] {
+		case '[':
+			j := i + 2
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7E) {
+				j++
+			}
+			if j >= len(s) {
+				// 不完整的控制序列
+				i = j
+				continue
+			}
+			if s[j] == 'm' {
+				style := p.style
+				style.apply(s[i+2 : j])
+				if style != p.style {
+					flush()
+					p.style = style
+				}
+			}
+			i = j + 1
+		case ']', 'P', 'X', '^', '_':
+			// OSC、DCS 等字符串，直到 BEL 或 ST
+			j := i + 2
+			for j < len(s) && s[j] != '\x07' && !(s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\') {
+				j++
+			}
+			if j < len(s) && s[j] == '\x1b' {
+				j++
+			}
+			i = j + 1
+		default:
+			i += 2
+		}
+	}
+	flush()
+	return line
+}
+
+// apply 应用 SGR 参数，如 "1;31"、"38;5;208"、"38;2;255;128;0"、"38:2::255:128:0"
+func (s *Style) apply(params string) {
+	if params == "" {
+		*s = Style{}
+		return
+	}
+	args := strings.Split(params, ";")
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// 冒号分隔的子参数（ITU T.416）：38:5:n、38:2::r:g:b、38:2:r:g:b
+		var sub []string
+		if strings.IndexByte(arg, ':') >= 0 {
+			sub = strings.Split(arg, ":")
+			arg = sub[0]
+		}
+		n := atoi(arg)
+		switch {
+		case n == 0:
+			*s = Style{}
+		case n == 1:
+			s.Attr |= Bold
+		case n == 2:
+			s.Attr |= Dim
+		case n == 3:
+			s.Attr |= Italic
+		case n == 4 || n == 21:
+			s.Attr |= Underline
+		case n == 5 || n == 6:
+			s.Attr |= Blink
+		case n == 7:
+			s.Attr |= Reverse
+		case n == 8:
+			s.Attr |= Hidden
+		case n == 9:
+			s.Attr |= Strike
+		case n == 22:
+			s.Attr &^= Bold | Dim
+		case n == 23:
+			s.Attr &^= Italic
+		case n == 24:
+			s.Attr &^= Underline
+		case n == 25:
+			s.Attr &^= Blink
+		case n == 27:
+			s.Attr &^= Reverse
+		case n == 28:
+			s.Attr &^= Hidden
+		case n == 29:
+			s.Attr &^= Strike
+		case n >= 30 && n <= 37:
+			s.Fg = Indexed(uint8(n - 30))
+		case n == 39:
+			s.Fg = Color{}
+		case n >= 40 && n <= 47:
+			s.Bg = Indexed(uint8(n - 40))
+		case n == 49:
+			s.Bg = Color{}
+		case n >= 90 && n <= 97:
+			s.Fg = Indexed(uint8(n - 90 + 8))
+		case n >= 100 && n <= 107:
+			s.Bg = Indexed(uint8(n - 100 + 8))
+		case n == 38 || n == 48:
+			var c Color
+			var ok bool
+			if sub != nil {
+				c, ok = extendedColor(sub[1:], true)
+			} else {
+				var used int
+				c, ok, used = extendedColorArgs(args[i+1:])
+				i += used
+			}
+			if ok {
+				if n == 38 {
+					s.Fg = c
+				} else {
+					s.Bg = c
+				}
+			}
+		}
+	}
+}
+
+// extendedColorArgs 解析分号分隔的扩展颜色：5;n 或 2;r;g;b，返回使用的参数个数
+func extendedColorArgs(args []string) (Color, bool, int) {
+	if len(args) == 0 {
+		return Color{}, false, 0
+	}
+	switch atoi(args[0]) {
+	case 5:
+		if len(args) < 2 {
+			return Color{}, false, len(args)
+		}
+		c, ok := extendedColor(args[:2], false)
+		return c, ok, 2
+	case 2:
+		if len(args) < 4 {
+			return Color{}, false, len(args)
+		}
+		c, ok := extendedColor(args[:4], false)
+		return c, ok, 4
+	}
+	return Color{}, false, 1
+}
+
+// extendedColor 解析扩展颜色：5,n 或 2,r,g,b；colon 为 true 时 2 之后可以有一个颜色空间参数（通常为空）
+func extendedColor(args []string, colon bool) (Color, bool) {
+	if len(args) == 0 {
+		return Color{}, false
+	}
+	switch atoi(args[0]) {
+	case 5:
+		if len(args) >= 2 {
+			if n := atoi(args[1]); n >= 0 && n <= 255 {
+				return Indexed(uint8(n)), true
+			}
+		}
+	case 2:
+		rgb := args[1:]
+		if colon && len(rgb) >= 4 {
+			rgb = rgb[1:]
+		}
+		if len(rgb) >= 3 {
+			r, g, b := atoi(rgb[0]), atoi(rgb[1]), atoi(rgb[2])
+			if r >= 0 && r <= 255 && g >= 0 && g <= 255 && b >= 0 && b <= 255 {
+				return RGB(uint8(r), uint8(g), uint8(b)), true
+			}
+		}
+	}
+	return Color{}, false
+}
+
+// atoi 解析参数，空参数为 0，非法参数为 -1
+func atoi(s string) int {
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return n
+}
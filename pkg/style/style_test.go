@@ -0,0 +1,60 @@
+package style
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	var p Parser
+	line := p.Parse("plain \x1b[1;31mred\x1b[0m \x1b[38;5;208morange\x1b[39m \x1b[48;2;1;2;3mrgb\x1b[m")
+	assert.Equal(t, Line{
+		{Text: "plain "},
+		{Text: "red", Style: Style{Fg: Indexed(1), Attr: Bold}},
+		{Text: " "},
+		{Text: "orange", Style: Style{Fg: Indexed(208)}},
+		{Text: " "},
+		{Text: "rgb", Style: Style{Bg: RGB(1, 2, 3)}},
+	}, line)
+	assert.Equal(t, "plain red orange rgb", line.Text())
+
+	// 冒号分隔的子参数、高亮颜色、字体属性的开关
+	line = p.Parse("\x1b[38:2::255:128:0;4ma\x1b[38:5:9;24;95mb\x1b[7;3mc\x1b[27;23;105md")
+	assert.Equal(t, Line{
+		{Text: "a", Style: Style{Fg: RGB(255, 128, 0), Attr: Underline}},
+		{Text: "b", Style: Style{Fg: Indexed(13)}},
+		{Text: "c", Style: Style{Fg: Indexed(13), Attr: Reverse | Italic}},
+		{Text: "d", Style: Style{Fg: Indexed(13), Bg: Indexed(13)}},
+	}, line)
+
+	// 样式在多行之间延续，相同的样式合并，其他控制序列被删除
+	line = p.Parse("e\x1b[1m\x1b[22mf\x1b[K\x1b]0;title\x07g\x1b[0")
+	assert.Equal(t, Line{{Text: "efg", Style: Style{Fg: Indexed(13), Bg: Indexed(13)}}}, line)
+	p.Reset()
+	assert.True(t, p.Style().IsDefault())
+
+	// 非法的扩展颜色被忽略
+	assert.Equal(t, Line{{Text: "x", Style: Style{Attr: Bold}}}, p.Parse("\x1b[38;5;256;1mx"))
+}
+
+func TestColor_Hex(t *testing.T) {
+	assert.Equal(t, "", Color{}.Hex())
+	assert.Equal(t, "#cd0000", Indexed(1).Hex())
+	assert.Equal(t, "#ffffff", Indexed(15).Hex())
+	assert.Equal(t, "#ff8700", Indexed(208).Hex())
+	assert.Equal(t, "#080808", Indexed(232).Hex())
+	assert.Equal(t, "#eeeeee", Indexed(255).Hex())
+	assert.Equal(t, "#0a0b0c", RGB(10, 11, 12).Hex())
+}
+
+func TestRender(t *testing.T) {
+	var p Parser
+	line := p.Parse("a<b> \x1b[1;4;9;31mred\x1b[0m \x1b[7mrev\x1b[0m \x1b[38;2;1;2;3;2mrgb")
+	assert.Equal(t, "a<b> red rev rgb", Plain().Render(line))
+	assert.Equal(t, `a&lt;b&gt; <span style="color:#cd0000;font-weight:bold;text-decoration:underline line-through">red</span> `+
+		`<span style="color:#ffffff;background-color:#000000">rev</span> <span style="color:#010203;opacity:0.5">rgb</span>`,
+		HTML().Render(line))
+	assert.Equal(t, `a&lt;b&gt; <span class="t-fg-1 t-bold t-underline t-strike">red</span> `+
+		`<span class="t-reverse" style="color:#ffffff;background-color:#000000">rev</span> <span class="t-dim" style="color:#010203">rgb</span>`,
+		HTML(HTMLOptions{ClassPrefix: "t-"}).Render(line))
+}
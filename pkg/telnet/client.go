@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/dialer"
 	"net"
 	"regexp"
 	"strings"
@@ -34,6 +35,7 @@ type ClientConfig struct {
 	Echo          bool           // 如果设置，将允许回显（取决于服务端是否支持），部分网络设备上无效（总是回显）
 	SuppressGA    bool           // 如果设置，将抑制 "go ahead" 命令
 	TLS           *tls.Config
+	Dialer        dialer.Dialer // 自定义的连接方式，如通过 SOCKS5、HTTP 代理连接，默认直接连接
 }
 
 func NewClient(cfg *ClientConfig) (*Client, error) {
@@ -57,7 +59,9 @@ func NewClient(cfg *ClientConfig) (*Client, error) {
 		c   net.Conn
 		err error
 	)
-	if cfg.TLS != nil {
+	if cfg.Dialer != nil {
+		c, err = dialWithDialer(cfg)
+	} else if cfg.TLS != nil {
 		c, err = tls.DialWithDialer(&net.Dialer{Timeout: cfg.Timeout}, "tcp", cfg.Addr, cfg.TLS)
 	} else {
 		c, err = net.DialTimeout("tcp", cfg.Addr, cfg.Timeout)
@@ -83,6 +87,32 @@ func NewClient(cfg *ClientConfig) (*Client, error) {
 	return client, err
 }
 
+// dialWithDialer 使用自定义的 Dialer 建立连接，如果指定了 TLS，则在连接建立后进行 TLS 握手
+func dialWithDialer(cfg *ClientConfig) (net.Conn, error) {
+	start := time.Now()
+	c, err := dialer.Dial(cfg.Dialer, "tcp", cfg.Addr, cfg.Timeout)
+	if err != nil || cfg.TLS == nil {
+		return c, err
+	}
+
+	tlsCfg := cfg.TLS
+	if tlsCfg.ServerName == "" {
+		// 与 tls.DialWithDialer 一致，未指定 ServerName 时使用地址中的主机名
+		tlsCfg = tlsCfg.Clone()
+		if host, _, e := net.SplitHostPort(cfg.Addr); e == nil {
+			tlsCfg.ServerName = host
+		}
+	}
+	tc := tls.Client(c, tlsCfg)
+	_ = tc.SetDeadline(start.Add(cfg.Timeout))
+	if err = tc.Handshake(); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	_ = tc.SetDeadline(time.Time{})
+	return tc, nil
+}
+
 func (this *Client) Close() error {
 	return this.c.Close()
 }
@@ -0,0 +1,150 @@
+package telnet
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+)
+
+// Server telnet 服务端，用于在本地模拟设备（参考 pkg/simulator）
+type Server struct {
+	ln      net.Listener
+	handler func(c *ServerConn)
+	mu      sync.Mutex
+	conns   map[*ServerConn]struct{}
+	wg      sync.WaitGroup
+}
+
+// NewServer 监听指定的地址，每个连接在单独的协程中调用 handler，handler 返回后关闭连接
+//
+//	连接建立后服务端声明 WILL ECHO、WILL SGA（与大多数网络设备一致），客户端的协商请求均被忽略
+func NewServer(addr string, handler func(c *ServerConn)) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{ln: ln, handler: handler, conns: map[*ServerConn]struct{}{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close 停止监听，并关闭所有连接
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		sc := &ServerConn{c: c, r: bufio.NewReader(c)}
+		s.mu.Lock()
+		s.conns[sc] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				_ = sc.Close()
+				s.mu.Lock()
+				delete(s.conns, sc)
+				s.mu.Unlock()
+			}()
+			if _, err := c.Write([]byte{cmd_IAC, cmd_WILL, opt_ECHO, cmd_IAC, cmd_WILL, opt_SGA}); err != nil {
+				return
+			}
+			s.handler(sc)
+		}()
+	}
+}
+
+// ServerConn 服务端的 telnet 连接，读取时过滤客户端的协商命令，写入时转义 IAC
+type ServerConn struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+func (c *ServerConn) RemoteAddr() net.Addr {
+	return c.c.RemoteAddr()
+}
+
+func (c *ServerConn) Close() error {
+	return c.c.Close()
+}
+
+func (c *ServerConn) Read(buf []byte) (int, error) {
+	var n int
+	for n < len(buf) {
+		// 至少读取一个字节后，不再等待更多的数据
+		if n > 0 && c.r.Buffered() == 0 {
+			break
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				break
+			}
+			return 0, err
+		}
+		if b != cmd_IAC {
+			buf[n] = b
+			n++
+			continue
+		}
+		if b, err = c.r.ReadByte(); err != nil {
+			return n, err
+		}
+		switch b {
+		case cmd_IAC:
+			buf[n] = b
+			n++
+		case cmd_WILL, cmd_WONT, cmd_DO, cmd_DONT:
+			if _, err = c.r.ReadByte(); err != nil {
+				return n, err
+			}
+		case cmd_SB:
+			// 跳过子协商，直到 IAC SE
+			for {
+				if b, err = c.r.ReadByte(); err != nil {
+					return n, err
+				}
+				if b == cmd_IAC {
+					if b, err = c.r.ReadByte(); err != nil {
+						return n, err
+					}
+					if b == cmd_SE {
+						break
+					}
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+func (c *ServerConn) Write(buf []byte) (int, error) {
+	if bytes.IndexByte(buf, cmd_IAC) == -1 {
+		return c.c.Write(buf)
+	}
+	if _, err := c.c.Write(bytes.ReplaceAll(buf, []byte{cmd_IAC}, []byte{cmd_IAC, cmd_IAC})); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
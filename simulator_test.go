@@ -0,0 +1,165 @@
+package easyshell
+
+import (
+	"context"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/interceptor"
+	"github.com/3th1nk/easyshell/pkg/simulator"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func loadTestScenario(t *testing.T) *simulator.Scenario {
+	sc, err := simulator.LoadScenario("pkg/simulator/testdata/device.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+// testSimulatorDevice 在模拟设备上执行常见的操作：分页、切换配置模式、确认、耗时较长的命令、断开连接
+func testSimulatorDevice(t *testing.T, rw *core.ReadWriter) {
+	run := func(cmd string, opts ...core.RunOption) *core.Result {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		res, err := rw.Run(ctx, cmd, opts...)
+		assert.NoError(t, err, cmd)
+		return res
+	}
+
+	res := run("show running-config")
+	assert.Equal(t, []string{"hostname SW1", "interface Eth1", "interface Eth2", "interface Eth3", "end"}, res.Lines)
+	assert.Len(t, res.Interceptions, 2)
+
+	res = run("configure terminal")
+	assert.Equal(t, []string{"Enter configuration commands, one per line."}, res.Lines)
+	assert.Equal(t, "SW1(config)# ", res.Prompt)
+	res = run("end")
+	assert.Equal(t, "SW1# ", res.Prompt)
+
+	res = run("reload", core.WithInterceptors(interceptor.AlwaysNo()))
+	assert.Equal(t, []string{"Reload cancelled."}, res.Lines)
+
+	res = run("ping 10.0.0.1")
+	assert.Equal(t, []string{"!!!!!", "Success rate is 100 percent (5/5)"}, res.Lines)
+	assert.GreaterOrEqual(t, res.Duration(), 300*time.Millisecond)
+
+	assert.NoError(t, rw.Write("quit"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out []string
+	assert.NoError(t, rw.ReadAllContext(ctx, func(lines []string) { out = append(out, lines...) }))
+	assert.Contains(t, out, "Bye")
+}
+
+func TestSimulator_SshShell(t *testing.T) {
+	server, err := simulator.NewSshServer("127.0.0.1:0", loadTestScenario(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	s, err := NewSshShell(&SshShellConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "admin", Password: "secret"},
+		Config:     core.Config{InitCommands: []string{"terminal length 0"}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	// 登录后的密码过期提示被自动答复
+	assert.Contains(t, s.HeadLine(), "*  Welcome to SW1 (simulator)  *")
+	assert.Contains(t, s.HeadLine(), "Password not changed.")
+	assert.Equal(t, "SW1# ", s.Prompt())
+	assert.Contains(t, server.Inputs(), "terminal length 0")
+
+	testSimulatorDevice(t, s.ReadWriter)
+}
+
+func TestSimulator_TelnetShell(t *testing.T) {
+	server, err := simulator.NewTelnetServer("127.0.0.1:0", loadTestScenario(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	s, err := NewTelnetShell(&TelnetShellConfig{
+		Credential: &TelnetCredential{Host: server.Host(), Port: server.Port(), User: "admin", Password: "secret", Timeout: 5 * time.Second},
+		Config:     core.Config{InitCommands: []string{"terminal length 0"}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	assert.Contains(t, s.HeadLine(), "*  Welcome to SW1 (simulator)  *")
+	assert.Equal(t, "SW1# ", s.Prompt())
+	assert.Equal(t, []string{"admin", "secret"}, server.Inputs()[:2])
+	assert.Contains(t, server.Inputs(), "terminal length 0")
+
+	testSimulatorDevice(t, s.ReadWriter)
+}
+
+func TestSimulator_TelnetShellReconnect(t *testing.T) {
+	server, err := simulator.NewTelnetServer("127.0.0.1:0", loadTestScenario(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	s, err := NewTelnetShell(&TelnetShellConfig{
+		Credential: &TelnetCredential{Host: server.Host(), Port: server.Port(), User: "admin", Password: "secret", Timeout: 5 * time.Second},
+		Config: core.Config{
+			InitCommands: []string{"terminal length 0"},
+			Reconnect:    core.ReconnectPolicy{MaxAttempts: 3, Backoff: 50 * time.Millisecond},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	countInit := func() (n int) {
+		for _, v := range server.Inputs() {
+			if v == "terminal length 0" {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Equal(t, 1, countInit())
+
+	// 命令执行过程中连接断开：返回不可重试的错误，并自动重新登录、重新执行初始化命令
+	assert.NoError(t, s.Write("ping 10.0.0.1"))
+	time.Sleep(100 * time.Millisecond)
+	server.Disconnect()
+	err = s.ReadToEndLine(5*time.Second, nil)
+	if v, _ := err.(*core.ReconnectError); assert.NotNil(t, v, err) {
+		assert.False(t, v.Retryable)
+		assert.True(t, v.Reconnected)
+	}
+	assert.Equal(t, 2, countInit())
+	assert.Equal(t, "SW1# ", s.Prompt())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := s.Run(ctx, "show version")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Software Version 1.2.3", "Uptime is 10 days"}, res.Lines)
+	}
+}
+
+func TestSimulator_TelnetLoginFailed(t *testing.T) {
+	server, err := simulator.NewTelnetServer("127.0.0.1:0", loadTestScenario(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	_, err = NewTelnetShell(&TelnetShellConfig{
+		Credential: &TelnetCredential{Host: server.Host(), Port: server.Port(), User: "admin", Password: "wrong", Timeout: 5 * time.Second},
+	})
+	assert.EqualError(t, err, "invalid username or password")
+}
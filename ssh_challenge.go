@@ -0,0 +1,102 @@
+package easyshell
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// OtpQuestionRegex 匹配要求输入动态口令的问题
+	OtpQuestionRegex = regexp.MustCompile(`(?i)(otp|one[-\s]?time|token|verification|passcode|authenticator|\bcode\b)`)
+	// PushQuestionRegex 匹配要求选择认证方式的问题，如 Duo: "Passcode or option (1-3):"
+	PushQuestionRegex = regexp.MustCompile(`(?i)(option\s*\(\d+-\d+\)|\bpush\b)`)
+)
+
+// SshChallengeHandler 键盘交互认证(keyboard-interactive)的应答函数
+//
+//	user 用户名，instruction 服务端的说明信息，questions 服务端的问题，echos 对应问题的输入是否回显（通常密码类问题不回显）
+//	返回值 answers 的长度必须与 questions 一致
+type SshChallengeHandler func(user, instruction string, questions []string, echos []bool) (answers []string, err error)
+
+// SshChallengeRule 键盘交互认证的应答规则
+type SshChallengeRule struct {
+	Regex  *regexp.Regexp         // 匹配问题的规则
+	Answer func() (string, error) // 生成答案，如返回密码、动态口令、推送确认选项等
+}
+
+// NewSshChallengeHandler 根据规则回答键盘交互认证的问题
+//
+//	每个问题使用第一个匹配的规则作答，没有匹配任何规则的问题使用 password 作答
+func NewSshChallengeHandler(password string, rules ...SshChallengeRule) SshChallengeHandler {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+	loop:
+		for i, question := range questions {
+			for _, rule := range rules {
+				if rule.Regex != nil && rule.Regex.MatchString(question) {
+					answer, err := rule.Answer()
+					if err != nil {
+						return nil, err
+					}
+					answers[i] = answer
+					continue loop
+				}
+			}
+			answers[i] = password
+		}
+		return answers, nil
+	}
+}
+
+// TotpRule 使用基于时间的动态口令(RFC 6238)回答动态口令类问题
+//
+//	seed 为 base32 编码的密钥
+func TotpRule(seed string) SshChallengeRule {
+	return SshChallengeRule{
+		Regex: OtpQuestionRegex,
+		Answer: func() (string, error) {
+			return TotpCode(seed, time.Now())
+		},
+	}
+}
+
+// PushRule 回答选择认证方式的问题，answer 为推送确认对应的选项，如 Duo 的 "1" 或 "push"
+func PushRule(answer string) SshChallengeRule {
+	return SshChallengeRule{
+		Regex: PushQuestionRegex,
+		Answer: func() (string, error) {
+			return answer, nil
+		},
+	}
+}
+
+// TotpChallenge 动态口令类问题使用 seed 生成的动态口令作答，其他问题使用 password 作答
+func TotpChallenge(password, seed string) SshChallengeHandler {
+	return NewSshChallengeHandler(password, TotpRule(seed))
+}
+
+// TotpCode 根据 base32 编码的密钥，生成指定时间的 6 位动态口令（RFC 6238，HMAC-SHA1，30秒步长）
+func TotpCode(seed string, t time.Time) (string, error) {
+	seed = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(seed), " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(seed, "="))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp seed: %v", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(t.Unix()/30))
+	h := hmac.New(sha1.New, key)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+
+	// RFC 4226 动态截断
+	offset := sum[len(sum)-1] & 0x0F
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+	return fmt.Sprintf("%06d", code%1000000), nil
+}
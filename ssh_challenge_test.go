@@ -0,0 +1,79 @@
+package easyshell
+
+import (
+	"fmt"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B的测试密钥 "12345678901234567890"
+const testTotpSeed = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	for _, obj := range []struct {
+		Unix int64
+		Code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		code, err := TotpCode(testTotpSeed, time.Unix(obj.Unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, obj.Code, code)
+	}
+
+	// 小写、空格、填充符
+	code, err := TotpCode("gezd gnbv gy3t qojq gezd gnbv gy3t qojq====", time.Unix(59, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	_, err = TotpCode("not-base32!", time.Now())
+	assert.Error(t, err)
+}
+
+func TestNewSshChallengeHandler(t *testing.T) {
+	handler := NewSshChallengeHandler("123456", PushRule("1"), TotpRule(testTotpSeed))
+	answers, err := handler("test", "", []string{"Password: ", "Passcode or option (1-3): ", "Verification code: "}, []bool{false, true, true})
+	assert.NoError(t, err)
+	assert.Len(t, answers, 3)
+	assert.Equal(t, "123456", answers[0])
+	assert.Equal(t, "1", answers[1])
+	assert.Len(t, answers[2], 6)
+
+	handler = NewSshChallengeHandler("123456", SshChallengeRule{Regex: OtpQuestionRegex, Answer: func() (string, error) {
+		return "", fmt.Errorf("no token")
+	}})
+	_, err = handler("test", "", []string{"Token: "}, []bool{true})
+	assert.Error(t, err)
+}
+
+func TestNewSshClient_ChallengeHandler(t *testing.T) {
+	server := newTestSshServer(t, "test", "", withTestChallenge(func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+		answers, err := client(conn.User(), "MFA required", []string{"Password: ", "Verification code: "}, []bool{false, true})
+		if err != nil {
+			return nil, err
+		}
+		// 允许前一个时间窗口的动态口令，避免跨越窗口边界时测试失败
+		now, _ := TotpCode(testTotpSeed, time.Now())
+		prev, _ := TotpCode(testTotpSeed, time.Now().Add(-30*time.Second))
+		if len(answers) != 2 || answers[0] != "test@123" || (answers[1] != now && answers[1] != prev) {
+			return nil, fmt.Errorf("invalid answers")
+		}
+		return nil, nil
+	}))
+
+	// 默认使用密码回答所有问题，动态口令错误
+	_, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"})
+	assert.True(t, core.IsAuth(err))
+
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", ChallengeHandler: TotpChallenge("test@123", testTotpSeed)})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+}
@@ -0,0 +1,859 @@
+package easyshell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/dialer"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testSshServer 进程内的 ssh 服务端，用于离线测试
+type testSshServer struct {
+	t              *testing.T
+	ln             net.Listener
+	config         *ssh.ServerConfig
+	signer         ssh.Signer
+	mu             sync.Mutex
+	conns          []net.Conn
+	authorizedKeys []ssh.PublicKey
+	userCA         ssh.PublicKey
+	hostCA         ssh.Signer
+	listenAddr     string
+	challenge      func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error)
+	hostKeys       []ssh.Signer // 额外的主机密钥
+	dropGlobalReqs int32        // 不为 0 时不回复全局请求（模拟连接假死）
+	commands       []string     // testShell 收到的命令
+}
+
+type testSshServerOption func(s *testSshServer)
+
+// withTestListenAddr 监听指定的地址，默认监听随机端口
+func withTestListenAddr(addr string) testSshServerOption {
+	return func(s *testSshServer) { s.listenAddr = addr }
+}
+
+// withTestChallenge 启用键盘交互认证
+func withTestChallenge(f func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error)) testSshServerOption {
+	return func(s *testSshServer) { s.challenge = f }
+}
+
+// withTestUserCA 信任指定 CA 签发的用户证书
+func withTestUserCA(ca ssh.PublicKey) testSshServerOption {
+	return func(s *testSshServer) { s.userCA = ca }
+}
+
+// withTestHostKey 额外使用指定的主机密钥
+func withTestHostKey(signer ssh.Signer) testSshServerOption {
+	return func(s *testSshServer) { s.hostKeys = append(s.hostKeys, signer) }
+}
+
+// withTestHostCA 使用指定 CA 签发的主机证书
+func withTestHostCA(ca ssh.Signer) testSshServerOption {
+	return func(s *testSshServer) { s.hostCA = ca }
+}
+
+func newTestSshServer(t *testing.T, user, password string, opts ...testSshServerOption) *testSshServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testSshServer{t: t, signer: signer, listenAddr: "127.0.0.1:0"}
+	for _, opt := range opts {
+		opt(s)
+	}
+	certChecker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return s.userCA != nil && bytes.Equal(auth.Marshal(), s.userCA.Marshal())
+		},
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", conn.User())
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if _, ok := key.(*ssh.Certificate); ok {
+				return certChecker.Authenticate(conn, key)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, k := range s.authorizedKeys {
+				if conn.User() == user && bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("public key rejected for %q", conn.User())
+		},
+	}
+	s.config.KeyboardInteractiveCallback = s.challenge
+	s.config.AddHostKey(signer)
+	for _, v := range s.hostKeys {
+		s.config.AddHostKey(v)
+	}
+	if s.hostCA != nil {
+		s.config.AddHostKey(newTestCertSigner(t, s.hostCA, signer, ssh.HostCert, "127.0.0.1"))
+	}
+
+	if s.ln, err = net.Listen("tcp", s.listenAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Authorize 允许指定的公钥登录
+func (s *testSshServer) Authorize(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizedKeys = append(s.authorizedKeys, key)
+}
+
+func (s *testSshServer) Host() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *testSshServer) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSshServer) Close() {
+	_ = s.ln.Close()
+	s.DropConns()
+}
+
+// DropConns 断开所有已建立的连接（模拟网络中断、设备重启），但继续接受新的连接
+func (s *testSshServer) DropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+	s.conns = nil
+}
+
+// Commands 返回 testShell 收到的命令
+func (s *testSshServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *testSshServer) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, c)
+		s.mu.Unlock()
+		go s.handleConn(c)
+	}
+}
+
+func (s *testSshServer) handleConn(c net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(c, s.config)
+	if err != nil {
+		_ = c.Close()
+		return
+	}
+	go s.handleGlobalRequests(sconn, reqs)
+	for ch := range chans {
+		switch ch.ChannelType() {
+		case "direct-tcpip":
+			go s.handleDirectTcpip(ch)
+		case "session":
+			go s.handleSession(ch)
+		default:
+			_ = ch.Reject(ssh.UnknownChannelType, ch.ChannelType())
+		}
+	}
+}
+
+// handleGlobalRequests 处理全局请求：keepalive、远程端口转发(tcpip-forward)
+func (s *testSshServer) handleGlobalRequests(sconn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	type forwardPayload struct {
+		Addr string
+		Port uint32
+	}
+	forwards := map[string]net.Listener{}
+	defer func() {
+		for _, ln := range forwards {
+			_ = ln.Close()
+		}
+	}()
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			var payload forwardPayload
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			ln, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			port := uint32(ln.Addr().(*net.TCPAddr).Port)
+			forwards[net.JoinHostPort(payload.Addr, strconv.Itoa(int(port)))] = ln
+			_ = req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+			go s.serveForwarded(sconn, ln, payload.Addr, port)
+		case "cancel-tcpip-forward":
+			var payload forwardPayload
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			key := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+			if ln := forwards[key]; ln != nil {
+				_ = ln.Close()
+				delete(forwards, key)
+			}
+			_ = req.Reply(true, nil)
+		default:
+			if atomic.LoadInt32(&s.dropGlobalReqs) == 0 && req.WantReply {
+				_ = req.Reply(req.Type == "keepalive@openssh.com", nil)
+			}
+		}
+	}
+}
+
+// serveForwarded 将远程端口转发监听到的连接通过 forwarded-tcpip 通道转发给客户端
+func (s *testSshServer) serveForwarded(sconn *ssh.ServerConn, ln net.Listener, addr string, port uint32) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		origin := c.RemoteAddr().(*net.TCPAddr)
+		ch, reqs, err := sconn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+			Addr       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}{addr, port, origin.IP.String(), uint32(origin.Port)}))
+		if err != nil {
+			_ = c.Close()
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			go func() {
+				_, _ = io.Copy(ch, c)
+				_ = ch.CloseWrite()
+			}()
+			_, _ = io.Copy(c, ch)
+			_ = c.Close()
+			_ = ch.Close()
+		}()
+	}
+}
+
+// handleDirectTcpip 处理端口转发请求（跳板机、本地转发）
+func (s *testSshServer) handleDirectTcpip(newCh ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newCh.ExtraData(), &payload); err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, target)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(target, ch)
+	_ = target.Close()
+	_ = ch.Close()
+}
+
+// handleSession 处理会话请求，exec 请求原样回显命令，shell 请求启动 testShell
+func (s *testSshServer) handleSession(newCh ssh.NewChannel) {
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	// 会话关闭时（请求通道关闭）通知 testShell
+	done := make(chan struct{})
+	defer close(done)
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			_ = req.Reply(true, nil)
+		case "shell":
+			_ = req.Reply(true, nil)
+			go func() {
+				testShell(ch, done, func(cmd string) {
+					s.mu.Lock()
+					s.commands = append(s.commands, cmd)
+					s.mu.Unlock()
+				})
+				_ = ch.Close()
+			}()
+		case "exec":
+			var payload struct{ Command string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			_ = req.Reply(true, nil)
+			if testExec(ch, payload.Command) {
+				return
+			}
+		case "signal":
+			// 模拟命令被信号终止
+			var payload struct{ Signal string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			testExitSignal(ch, payload.Signal)
+			return
+		default:
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// testExec 执行 exec 请求的命令，返回 false 时表示命令仍在执行
+//
+//	exit N: 以退出码 N 退出
+//	stderr xxx: 向标准错误输出 xxx，退出码为 1
+//	noeol xxx: 输出 xxx，末尾没有换行符
+//	signal xxx: 模拟被信号 xxx 终止
+//	hang: 不输出任何内容，直到收到信号
+//	hang xxx: 输出 xxx（末尾没有换行符），然后不再输出任何内容，直到收到信号
+//	其他: 原样输出
+func testExec(ch ssh.Channel, cmd string) bool {
+	status := 0
+	switch {
+	case cmd == "hang":
+		return false
+	case strings.HasPrefix(cmd, "hang "):
+		_, _ = ch.Write([]byte(strings.TrimPrefix(cmd, "hang ")))
+		return false
+	case strings.HasPrefix(cmd, "signal "):
+		testExitSignal(ch, strings.TrimPrefix(cmd, "signal "))
+		return true
+	case strings.HasPrefix(cmd, "exit "):
+		status, _ = strconv.Atoi(strings.TrimPrefix(cmd, "exit "))
+	case strings.HasPrefix(cmd, "stderr "):
+		_, _ = ch.Stderr().Write([]byte(strings.TrimPrefix(cmd, "stderr ") + "\n"))
+		status = 1
+	case strings.HasPrefix(cmd, "noeol "):
+		_, _ = ch.Write([]byte(strings.TrimPrefix(cmd, "noeol ")))
+	default:
+		_, _ = ch.Write([]byte(cmd + "\n"))
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(status))
+	_, _ = ch.SendRequest("exit-status", false, b)
+	return true
+}
+
+func testExitSignal(ch ssh.Channel, signal string) {
+	_, _ = ch.SendRequest("exit-signal", false, ssh.Marshal(struct {
+		Signal     string
+		CoreDumped bool
+		Error      string
+		Lang       string
+	}{Signal: signal}))
+}
+
+// testShell 简易的交互式命令行，提示符为 "test$ "
+//
+//	echo xxx: 输出 xxx
+//	hang: 不输出任何内容（模拟长时间执行的命令）
+//	block: 不再读取输入，直到会话关闭（模拟输入阻塞）
+//	exit: 退出
+//	其他: 原样输出
+func testShell(rw io.ReadWriter, done <-chan struct{}, onCommand func(cmd string)) {
+	_, _ = rw.Write([]byte("Welcome to test shell\r\ntest$ "))
+	scanner := bufio.NewScanner(rw)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && onCommand != nil {
+			onCommand(line)
+		}
+		switch {
+		case line == "":
+			_, _ = rw.Write([]byte("test$ "))
+		case line == "hang":
+		case line == "block":
+			<-done
+			return
+		case line == "exit":
+			return
+		case strings.HasPrefix(line, "echo "):
+			_, _ = rw.Write([]byte(strings.TrimPrefix(line, "echo ") + "\r\ntest$ "))
+		default:
+			_, _ = rw.Write([]byte(line + "\r\ntest$ "))
+		}
+	}
+}
+
+func testSshExec(t *testing.T, client *ssh.Client, cmd string) string {
+	session, err := client.NewSession()
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer session.Close()
+	out, err := session.Output(cmd)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestNewSshClient_JumpHosts(t *testing.T) {
+	target := newTestSshServer(t, "target", "target@123")
+	jump1 := newTestSshServer(t, "jump1", "jump1@123")
+	jump2 := newTestSshServer(t, "jump2", "jump2@123")
+
+	client, err := NewSshClient(&SshCredential{
+		Host:     target.Host(),
+		Port:     target.Port(),
+		User:     "target",
+		Password: "target@123",
+		JumpHosts: []*SshCredential{
+			{Host: jump1.Host(), Port: jump1.Port(), User: "jump1", Password: "jump1@123"},
+			{Host: jump2.Host(), Port: jump2.Port(), User: "jump2", Password: "jump2@123"},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+
+	assert.Equal(t, "target", client.User())
+	assert.Equal(t, "echo hello\n", testSshExec(t, client, "echo hello"))
+}
+
+func TestNewSshClient_JumpHostsError(t *testing.T) {
+	target := newTestSshServer(t, "target", "target@123")
+	jump := newTestSshServer(t, "jump", "jump@123")
+	jumpAddr := fmt.Sprintf("%s:%d", jump.Host(), jump.Port())
+	targetAddr := fmt.Sprintf("%s:%d", target.Host(), target.Port())
+
+	// 跳板机认证失败
+	_, err := NewSshClient(&SshCredential{
+		Host:      target.Host(),
+		Port:      target.Port(),
+		User:      "target",
+		Password:  "target@123",
+		JumpHosts: []*SshCredential{{Host: jump.Host(), Port: jump.Port(), User: "jump", Password: "wrong"}},
+	})
+	assert.True(t, core.IsAuth(err))
+	if v, _ := err.(*core.Error); assert.NotNil(t, v) {
+		assert.Equal(t, jumpAddr, v.Addr)
+	}
+
+	// 目标主机认证失败
+	_, err = NewSshClient(&SshCredential{
+		Host:      target.Host(),
+		Port:      target.Port(),
+		User:      "target",
+		Password:  "wrong",
+		JumpHosts: []*SshCredential{{Host: jump.Host(), Port: jump.Port(), User: "jump", Password: "jump@123"}},
+	})
+	assert.True(t, core.IsAuth(err))
+	if v, _ := err.(*core.Error); assert.NotNil(t, v) {
+		assert.Equal(t, targetAddr, v.Addr)
+	}
+
+	// 跳板机无法连接目标主机
+	target.Close()
+	_, err = NewSshClient(&SshCredential{
+		Host:      target.Host(),
+		Port:      target.Port(),
+		User:      "target",
+		Password:  "target@123",
+		JumpHosts: []*SshCredential{{Host: jump.Host(), Port: jump.Port(), User: "jump", Password: "jump@123"}},
+	})
+	assert.True(t, core.IsDial(err))
+	if v, _ := err.(*core.Error); assert.NotNil(t, v) {
+		assert.Equal(t, targetAddr, v.Addr)
+	}
+}
+
+func newTestPrivateKey(t *testing.T, passphrase string) (ed25519.PrivateKey, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(block))
+}
+
+func testPublicKey(t *testing.T, key ed25519.PrivateKey) ssh.PublicKey {
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestNewSshClient_EncryptedPrivateKey(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	key, pemStr := newTestPrivateKey(t, "secret")
+	server.Authorize(testPublicKey(t, key))
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, []byte(pemStr), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缺少保护密码
+	_, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKeyFile: keyFile})
+	assert.True(t, core.IsAuth(err))
+
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKeyFile: keyFile, Passphrase: "secret"})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+}
+
+func TestNewSshClient_Agent(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	key, _ := newTestPrivateKey(t, "")
+	server.Authorize(testPublicKey(t, key))
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", UseAgent: true, AgentSocket: sock})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	client, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", UseAgent: true})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+}
+
+func TestNewSshClient_AgentUnavailable(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	key, pemStr := newTestPrivateKey(t, "")
+	server.Authorize(testPublicKey(t, key))
+
+	// 没有 ssh-agent 时跳过，继续使用密钥、密码认证
+	t.Setenv("SSH_AUTH_SOCK", "")
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", UseAgent: true, PrivateKey: pemStr})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+	missing := filepath.Join(t.TempDir(), "missing.sock")
+	client, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", UseAgent: true, AgentSocket: missing, Password: "test@123"})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	// 没有其他认证方式时返回 ssh-agent 的错误
+	_, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", UseAgent: true, AgentSocket: missing})
+	if assert.True(t, core.IsAuth(err)) {
+		assert.Contains(t, err.Error(), "agent error")
+	}
+}
+
+func TestNewSshClient_FallbackToPassword(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	// 密钥未被服务端授权，认证失败后回退到密码认证
+	_, pemStr := newTestPrivateKey(t, "")
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKey: pemStr, Password: "test@123"})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	_, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKey: pemStr, Password: "wrong"})
+	assert.True(t, core.IsAuth(err))
+}
+
+func newTestCertSigner(t *testing.T, ca ssh.Signer, signer ssh.Signer, certType uint32, principals ...string) ssh.Signer {
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        certType,
+		ValidPrincipals: principals,
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certSigner
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	key, _ := newTestPrivateKey(t, "")
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestNewSshClient_UserCertificate(t *testing.T) {
+	userCA := newTestSigner(t)
+	server := newTestSshServer(t, "test", "test@123", withTestUserCA(userCA.PublicKey()))
+
+	key, pemStr := newTestPrivateKey(t, "")
+	signer, _ := ssh.NewSignerFromKey(key)
+	certSigner := newTestCertSigner(t, userCA, signer, ssh.UserCert, "test")
+	certStr := string(ssh.MarshalAuthorizedKey(certSigner.PublicKey()))
+
+	// 密钥本身未被授权，只能通过证书认证
+	_, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKey: pemStr})
+	assert.True(t, core.IsAuth(err))
+
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKey: pemStr, Certificate: certStr})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	// 证书的 principal 与用户名不一致
+	otherCertStr := string(ssh.MarshalAuthorizedKey(newTestCertSigner(t, userCA, signer, ssh.UserCert, "other").PublicKey()))
+	_, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKey: pemStr, Certificate: otherCertStr})
+	assert.True(t, core.IsAuth(err))
+
+	// 证书内容不是证书
+	_, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", PrivateKey: pemStr, Certificate: string(ssh.MarshalAuthorizedKey(signer.PublicKey()))})
+	assert.True(t, core.IsAuth(err))
+}
+
+func TestNewSshClient_HostCA(t *testing.T) {
+	hostCA := newTestSigner(t)
+	server := newTestSshServer(t, "test", "test@123", withTestHostCA(hostCA))
+	hostCAStr := string(ssh.MarshalAuthorizedKey(hostCA.PublicKey()))
+
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", HostCA: hostCAStr})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	// 不受信任的 CA
+	otherCAStr := string(ssh.MarshalAuthorizedKey(newTestSigner(t).PublicKey()))
+	_, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", HostCA: otherCAStr})
+	assert.True(t, core.IsHostKey(err))
+
+	// 多个 CA 中有一个受信任即可
+	client, err = NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", HostCA: otherCAStr + hostCAStr})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	// 服务器没有主机证书时，回退到指纹校验
+	plain := newTestSshServer(t, "test", "test@123")
+	_, err = NewSshClient(&SshCredential{Host: plain.Host(), Port: plain.Port(), User: "test", Password: "test@123", HostCA: hostCAStr})
+	assert.True(t, core.IsHostKey(err))
+	client, err = NewSshClient(&SshCredential{Host: plain.Host(), Port: plain.Port(), User: "test", Password: "test@123", HostCA: hostCAStr, Fingerprint: ssh.FingerprintSHA256(plain.signer.PublicKey())})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+}
+
+func TestNewSshClient_HostKeyPolicy(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	cred := func(policy string) *SshCredential {
+		return &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", HostKeyPolicy: policy, KnownHostsFile: knownHosts}
+	}
+
+	// known_hosts 不存在时，strict 拒绝连接
+	_, err := NewSshClient(cred(HostKeyPolicyStrict))
+	assert.True(t, core.IsHostKey(err))
+
+	// accept-new 接受新的主机，但不写入 known_hosts
+	client, err := NewSshClient(cred(HostKeyPolicyAcceptNew))
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+	_, err = os.Stat(knownHosts)
+	assert.True(t, os.IsNotExist(err))
+
+	// tofu 首次连接时写入 known_hosts
+	client, err = NewSshClient(cred(HostKeyPolicyTOFU))
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+	b, err := os.ReadFile(knownHosts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(server.signer.PublicKey()))))
+
+	// 写入后 strict 可以连接
+	client, err = NewSshClient(cred(HostKeyPolicyStrict))
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	// 服务器公钥变化（同一地址上的另一个服务器），所有策略都拒绝连接
+	server.Close()
+	newTestSshServer(t, "test", "test@123", withTestListenAddr(server.ln.Addr().String()))
+	for _, policy := range []string{HostKeyPolicyStrict, HostKeyPolicyTOFU, HostKeyPolicyAcceptNew} {
+		_, err = NewSshClient(cred(policy))
+		assert.True(t, core.IsHostKey(err), policy)
+	}
+
+	// 未知的策略
+	_, err = NewSshClient(cred("unknown"))
+	assert.True(t, core.IsHostKey(err))
+}
+
+func TestNewSshClient_HostKeyPolicyKeyType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSigner, err := ssh.NewSignerFromKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestSshServer(t, "test", "test@123", withTestHostKey(rsaSigner))
+	addr := server.ln.Addr().String()
+	cred := func(policy, knownHosts string) *SshCredential {
+		return &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", HostKeyPolicy: policy, KnownHostsFile: knownHosts}
+	}
+
+	// known_hosts 中只有 RSA 公钥，服务器同时支持 ed25519 时，优先使用 RSA 公钥
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	assert.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, rsaSigner.PublicKey())+"\n"), 0600))
+	client, err := NewSshClient(cred(HostKeyPolicyStrict, knownHosts))
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+
+	// known_hosts 中只有服务器不支持的公钥类型时，按未知主机处理：strict 拒绝连接，tofu 追加服务器的公钥
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaPub, err := ssh.NewPublicKey(&ecdsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHosts = filepath.Join(t.TempDir(), "known_hosts")
+	assert.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, ecdsaPub)+"\n"), 0600))
+	_, err = NewSshClient(cred(HostKeyPolicyStrict, knownHosts))
+	if assert.True(t, core.IsHostKey(err)) {
+		assert.Contains(t, err.Error(), "unknown")
+	}
+	client, err = NewSshClient(cred(HostKeyPolicyTOFU, knownHosts))
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+	client, err = NewSshClient(cred(HostKeyPolicyStrict, knownHosts))
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+}
+
+func TestNewSshClient_FingerprintMismatch(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	_, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", Fingerprint: "SHA256:invalid"})
+	assert.True(t, core.IsHostKey(err))
+	if v, _ := err.(*core.Error); assert.NotNil(t, v) {
+		assert.Equal(t, server.ln.Addr().String(), v.Addr)
+	}
+}
+
+func TestNewSshClient_Dialer(t *testing.T) {
+	target := newTestSshServer(t, "target", "target@123")
+	jump := newTestSshServer(t, "jump", "jump@123")
+
+	var dialed []string
+	d := dialer.Func(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return dialer.Direct().DialContext(ctx, network, addr)
+	})
+
+	client, err := NewSshClient(&SshCredential{Host: target.Host(), Port: target.Port(), User: "target", Password: "target@123", Dialer: d})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+	assert.Equal(t, []string{target.ln.Addr().String()}, dialed)
+
+	// 有跳板机时，只有第一跳使用 Dialer
+	dialed = nil
+	client, err = NewSshClient(&SshCredential{
+		Host:      target.Host(),
+		Port:      target.Port(),
+		User:      "target",
+		Password:  "target@123",
+		Dialer:    d,
+		JumpHosts: []*SshCredential{{Host: jump.Host(), Port: jump.Port(), User: "jump", Password: "jump@123"}},
+	})
+	if assert.NoError(t, err) {
+		_ = client.Close()
+	}
+	assert.Equal(t, []string{jump.ln.Addr().String()}, dialed)
+
+	// 代理地址无效
+	_, err = NewSshClient(&SshCredential{Host: target.Host(), Port: target.Port(), User: "target", Password: "target@123", Proxy: "ftp://127.0.0.1:21"})
+	assert.True(t, core.IsDial(err))
+}
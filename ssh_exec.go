@@ -0,0 +1,201 @@
+package easyshell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/internal/lineReader"
+	"github.com/3th1nk/easyshell/internal/misc"
+	"github.com/3th1nk/easyshell/pkg/charset"
+	"github.com/3th1nk/easyshell/pkg/filter"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"time"
+)
+
+type SshExecConfig struct {
+	Credential *SshCredential // 凭证
+
+	// 输出 stdout、stderr 中读取的原始数据，用于上层调试
+	RawOut io.Writer
+
+	// 用来创建过滤特殊字符的过滤器，在 Decoder 后执行；过滤器是有状态的，stdout、stderr 使用单独的过滤器
+	Filter filter.Factory
+
+	// 从 stdout、stderr 中读取到数据后，用来创建解码器（转换为 UTF8），默认使用 charset.Auto()，同一个 SshExec 执行的命令共享编码识别结果
+	Decoder charset.Factory
+}
+
+// SshExecResult 命令执行结果
+type SshExecResult struct {
+	Stdout   []string // 标准输出
+	Stderr   []string // 标准错误输出
+	ExitCode int      // 退出码，未获取到退出码（如超时、连接断开）时为 -1；被信号终止时为 128+信号值
+	Signal   string   // 终止命令的信号，如 KILL、TERM，正常退出时为空
+}
+
+// NewSshExec 以非交互方式(不分配 PTY)执行命令，每个命令使用独立的会话，可以获取命令的退出码，标准输出和标准错误输出分开返回
+func NewSshExec(config ...*SshExecConfig) (*SshExec, error) {
+	var cfg *SshExecConfig
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	} else {
+		cfg = &SshExecConfig{}
+	}
+
+	client, err := NewSshClient(cfg.Credential)
+	if err != nil {
+		return nil, err
+	}
+
+	e := NewSshExecFromClient(client, cfg)
+	e.ownClient = true
+	return e, nil
+}
+
+func NewSshExecFromClient(client *ssh.Client, config ...*SshExecConfig) *SshExec {
+	var cfg *SshExecConfig
+	if len(config) > 0 && config[0] != nil {
+		cfg = config[0]
+	} else {
+		cfg = &SshExecConfig{}
+	}
+
+	var opts []lineReader.Option
+	if !misc.IsNil(cfg.RawOut) {
+		opts = append(opts, lineReader.WithRawOut(cfg.RawOut))
+	}
+	if cfg.Filter != nil {
+		opts = append(opts, lineReader.WithFilter(cfg.Filter))
+	}
+	decoder := cfg.Decoder
+	if decoder == nil {
+		decoder = charset.Auto()
+	}
+	opts = append(opts, lineReader.WithDecoder(decoder))
+	return &SshExec{client: client, opts: opts}
+}
+
+type SshExec struct {
+	client    *ssh.Client
+	ownClient bool
+	opts      []lineReader.Option
+}
+
+func (this *SshExec) Client() *ssh.Client {
+	return this.client
+}
+
+// Exec 执行命令，并等待命令结束，参考 ExecContext
+func (this *SshExec) Exec(cmd string, timeout time.Duration, onStdout, onStderr func(lines []string)) (*SshExecResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return this.ExecContext(ctx, cmd, onStdout, onStderr)
+}
+
+// ExecContext 执行命令，并等待命令结束
+//
+//	onStdout、onStderr 实时返回读取到的标准输出、标准错误输出，返回的结果中同时包含全部输出
+//	命令以非 0 退出码退出、或被信号终止时不返回错误，需要检查结果中的 ExitCode、Signal
+//	超时或取消时，向命令发送 KILL 信号并关闭会话，返回已读取到的输出，以及 Op 为 timeout、canceled 的错误
+func (this *SshExec) ExecContext(ctx context.Context, cmd string, onStdout, onStderr func(lines []string)) (*SshExecResult, error) {
+	client := this.client
+	if client == nil {
+		return nil, &core.Error{Op: "session", Err: fmt.Errorf("client closed")}
+	}
+	addr := client.RemoteAddr().String()
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, &core.Error{Op: "session", Addr: addr, Err: err}
+	}
+	defer session.Close()
+
+	pOut, _ := session.StdoutPipe()
+	pErr, _ := session.StderrPipe()
+	stdout, stderr := lineReader.New(pOut, this.opts...), lineReader.New(pErr, this.opts...)
+	if err = session.Start(cmd); err != nil {
+		return nil, &core.Error{Op: "exec", Addr: addr, Err: err}
+	}
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- session.Wait()
+	}()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	result := &SshExecResult{ExitCode: -1}
+	var waitErr error
+	var exited, stdoutDone, stderrDone bool
+	for !exited || !stdoutDone || !stderrDone {
+		select {
+		case <-ctx.Done():
+			_ = session.Signal(ssh.SIGKILL)
+			_ = session.Close()
+			// 命令被终止，没有换行符的最后一行也一并返回
+			popExecLines(stdout, &result.Stdout, onStdout, true)
+			popExecLines(stderr, &result.Stderr, onStderr, true)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return result, &core.Error{Op: "timeout", Addr: addr, Err: ctx.Err()}
+			}
+			return result, &core.Error{Op: "canceled", Addr: addr, Err: ctx.Err()}
+
+		case waitErr = <-waitCh:
+			exited, waitCh = true, nil
+
+		case <-ticker.C:
+		}
+
+		if !stdoutDone {
+			stdoutDone = popExecLines(stdout, &result.Stdout, onStdout, false)
+		}
+		if !stderrDone {
+			stderrDone = popExecLines(stderr, &result.Stderr, onStderr, false)
+		}
+	}
+
+	switch v := waitErr.(type) {
+	case nil:
+		result.ExitCode = 0
+	case *ssh.ExitError:
+		result.ExitCode, result.Signal = v.ExitStatus(), v.Signal()
+	default:
+		// 包括 *ssh.ExitMissingError：服务端没有返回退出码，通常是连接断开
+		return result, &core.Error{Op: "exec", Addr: addr, Err: waitErr}
+	}
+	return result, nil
+}
+
+// popExecLines 取出已读取到的行，读取结束时返回 true（最后一行没有换行符时也一并返回）
+//
+//	flush 为 true 时，即使读取尚未结束，也返回没有换行符的最后一行（如超时或取消时）
+func popExecLines(lr *lineReader.LineReader, dst *[]string, onOut func(lines []string), flush bool) (done bool) {
+	var out []string
+	var remaining string
+	_, err := lr.PopLines(func(lines []string, r string) (dropRemaining bool) {
+		out = append(out, lines...)
+		remaining = r
+		return flush
+	})
+	if (err != nil || flush) && remaining != "" {
+		out = append(out, remaining)
+	}
+	if len(out) != 0 {
+		*dst = append(*dst, out...)
+		if onOut != nil {
+			onOut(out)
+		}
+	}
+	return err != nil
+}
+
+func (this *SshExec) Close() error {
+	if this.client != nil && this.ownClient {
+		err := this.client.Close()
+		this.client = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package easyshell
+
+import (
+	"bytes"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSshExec(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	var raw bytes.Buffer
+	e, err := NewSshExec(&SshExecConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+		RawOut:     &raw,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer e.Close()
+
+	var stdout []string
+	r, err := e.Exec("echo hello", 5*time.Second, func(lines []string) { stdout = append(stdout, lines...) }, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"echo hello"}, r.Stdout)
+		assert.Equal(t, []string{"echo hello"}, stdout)
+		assert.Empty(t, r.Stderr)
+		assert.Equal(t, 0, r.ExitCode)
+		assert.Equal(t, "", r.Signal)
+	}
+	assert.Equal(t, "echo hello\n", raw.String())
+
+	// 最后一行没有换行符
+	r, err = e.Exec("noeol a\r\nb", 5*time.Second, nil, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b"}, r.Stdout)
+	}
+
+	// 标准错误输出分开返回，非 0 退出码不返回错误
+	var stderr []string
+	r, err = e.Exec("stderr oops", 5*time.Second, nil, func(lines []string) { stderr = append(stderr, lines...) })
+	if assert.NoError(t, err) {
+		assert.Empty(t, r.Stdout)
+		assert.Equal(t, []string{"oops"}, r.Stderr)
+		assert.Equal(t, []string{"oops"}, stderr)
+		assert.Equal(t, 1, r.ExitCode)
+	}
+
+	r, err = e.Exec("exit 3", 5*time.Second, nil, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, r.ExitCode)
+	}
+}
+
+func TestSshExec_Timeout(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+	e := NewSshExecFromClient(client)
+
+	start := time.Now()
+	r, err := e.Exec("hang", 200*time.Millisecond, nil, nil)
+	assert.True(t, core.IsTimeout(err), err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	if assert.NotNil(t, r) {
+		assert.Equal(t, -1, r.ExitCode)
+	}
+
+	// 超时时返回没有换行符的最后一行
+	var stdout []string
+	r, err = e.Exec("hang a\r\nprogress 50%", 200*time.Millisecond, func(lines []string) { stdout = append(stdout, lines...) }, nil)
+	assert.True(t, core.IsTimeout(err), err)
+	if assert.NotNil(t, r) {
+		assert.Equal(t, []string{"a", "progress 50%"}, r.Stdout)
+		assert.Equal(t, r.Stdout, stdout)
+	}
+
+	// 不关闭调用方传入的 Client
+	assert.NoError(t, e.Close())
+	r, err = e.Exec("exit 0", 5*time.Second, nil, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, r.ExitCode)
+	}
+}
+
+func TestSshExec_Closed(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	e, err := NewSshExec(&SshExecConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, e.Close())
+
+	// 关闭后执行命令返回错误
+	r, err := e.Exec("exit 0", 5*time.Second, nil, nil)
+	assert.Nil(t, r)
+	if v, _ := err.(*core.Error); assert.NotNil(t, v, err) {
+		assert.Equal(t, "session", v.Op)
+	}
+}
+
+func TestSshExec_Signal(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	e, err := NewSshExec(&SshExecConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer e.Close()
+
+	r, err := e.Exec("signal TERM", 5*time.Second, nil, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "TERM", r.Signal)
+		assert.Equal(t, 128+15, r.ExitCode)
+	}
+}
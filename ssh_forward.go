@@ -0,0 +1,209 @@
+package easyshell
+
+import (
+	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/dialer"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SshForwardLocal   = "local"   // 本地转发：监听本地端口，通过 ssh 连接远端地址（ssh -L）
+	SshForwardRemote  = "remote"  // 远程转发：在服务端监听端口，连接本地地址（ssh -R）
+	SshForwardDynamic = "dynamic" // 动态转发：监听本地端口作为 SOCKS5 代理，通过 ssh 连接代理请求的地址（ssh -D）
+)
+
+// SshForward 端口转发
+type SshForward struct {
+	kind     string
+	ln       net.Listener
+	dial     func(c net.Conn) (net.Conn, error)
+	sent     int64
+	received int64
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+	onClose  func(f *SshForward)
+}
+
+// NewSshLocalForward 本地转发：监听本地地址 localAddr，将收到的连接通过 client 转发到远端地址 remoteAddr
+func NewSshLocalForward(client *ssh.Client, localAddr, remoteAddr string) (*SshForward, error) {
+	ln, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, &core.Error{Op: "forward", Addr: localAddr, Err: err}
+	}
+	return newSshForward(SshForwardLocal, ln, func(net.Conn) (net.Conn, error) {
+		return client.Dial("tcp", remoteAddr)
+	}), nil
+}
+
+// NewSshRemoteForward 远程转发：请求服务端监听地址 remoteAddr，将服务端收到的连接转发到本地地址 localAddr
+//
+//	remoteAddr 的端口为 0 时由服务端分配，可通过 Addr 获取实际监听的地址
+func NewSshRemoteForward(client *ssh.Client, remoteAddr, localAddr string) (*SshForward, error) {
+	ln, err := client.Listen("tcp", remoteAddr)
+	if err != nil {
+		return nil, &core.Error{Op: "forward", Addr: remoteAddr, Err: err}
+	}
+	return newSshForward(SshForwardRemote, ln, func(net.Conn) (net.Conn, error) {
+		return net.DialTimeout("tcp", localAddr, 30*time.Second)
+	}), nil
+}
+
+// NewSshDynamicForward 动态转发：监听本地地址 localAddr 作为 SOCKS5 代理（仅支持无认证方式），通过 client 连接代理请求的地址
+func NewSshDynamicForward(client *ssh.Client, localAddr string) (*SshForward, error) {
+	ln, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, &core.Error{Op: "forward", Addr: localAddr, Err: err}
+	}
+	return newSshForward(SshForwardDynamic, ln, func(c net.Conn) (net.Conn, error) {
+		return dialer.SOCKS5Handshake(c, client.Dial)
+	}), nil
+}
+
+func newSshForward(kind string, ln net.Listener, dial func(c net.Conn) (net.Conn, error)) *SshForward {
+	f := &SshForward{kind: kind, ln: ln, dial: dial, conns: map[net.Conn]struct{}{}}
+	go f.serve()
+	return f
+}
+
+// Type 转发类型：local、remote、dynamic
+func (f *SshForward) Type() string {
+	return f.kind
+}
+
+// Addr 监听的地址，远程转发时为服务端监听的地址
+func (f *SshForward) Addr() net.Addr {
+	return f.ln.Addr()
+}
+
+// BytesSent 从监听端接受的连接转发到目标地址的字节数
+func (f *SshForward) BytesSent() int64 {
+	return atomic.LoadInt64(&f.sent)
+}
+
+// BytesReceived 从目标地址转发回监听端接受的连接的字节数
+func (f *SshForward) BytesReceived() int64 {
+	return atomic.LoadInt64(&f.received)
+}
+
+// Close 停止监听，并断开所有正在转发的连接
+func (f *SshForward) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	conns, onClose := f.conns, f.onClose
+	f.conns = nil
+	f.mu.Unlock()
+
+	err := f.ln.Close()
+	for c := range conns {
+		_ = c.Close()
+	}
+	f.wg.Wait()
+	if onClose != nil {
+		onClose(f)
+	}
+	return err
+}
+
+// setOnClose 设置关闭时的回调，已关闭时返回 false
+func (f *SshForward) setOnClose(onClose func(f *SshForward)) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	f.onClose = onClose
+	return true
+}
+
+func (f *SshForward) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			// 停止监听或 ssh 连接已断开
+			_ = f.Close()
+			return
+		}
+		if !f.track(c, true) {
+			_ = c.Close()
+			return
+		}
+		go f.handle(c)
+	}
+}
+
+// track 记录正在转发的连接，已关闭时返回 false；add 为 true 时同时增加 WaitGroup 计数
+func (f *SshForward) track(c net.Conn, add bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	f.conns[c] = struct{}{}
+	if add {
+		f.wg.Add(1)
+	}
+	return true
+}
+
+func (f *SshForward) untrack(c net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.conns, c)
+}
+
+func (f *SshForward) handle(c net.Conn) {
+	defer f.wg.Done()
+	defer f.untrack(c)
+	defer c.Close()
+
+	target, err := f.dial(c)
+	if err != nil {
+		return
+	}
+	defer target.Close()
+	if !f.track(target, false) {
+		return
+	}
+	defer f.untrack(target)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(&countWriter{w: c, n: &f.received}, target)
+		closeWrite(c)
+	}()
+	_, _ = io.Copy(&countWriter{w: target, n: &f.sent}, c)
+	closeWrite(target)
+	<-done
+}
+
+// closeWrite 关闭连接的写方向，通知对端数据已发送完毕，不支持时直接关闭连接
+func closeWrite(c net.Conn) {
+	if v, ok := c.(interface{ CloseWrite() error }); ok {
+		_ = v.CloseWrite()
+	} else {
+		_ = c.Close()
+	}
+}
+
+type countWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	atomic.AddInt64(w.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,137 @@
+package easyshell
+
+import (
+	"github.com/3th1nk/easyshell/core"
+	"github.com/3th1nk/easyshell/pkg/dialer"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testEchoServer 原样返回收到的数据
+func testEchoServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func assertForwardEcho(t *testing.T, d dialer.Dialer, addr string) {
+	conn, err := dialer.Dial(d, "tcp", addr, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	assert.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
+
+func TestSshShell_Forward(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	echo := testEchoServer(t)
+	s, err := NewSshShell(&SshShellConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	local, err := s.LocalForward("127.0.0.1:0", echo)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, SshForwardLocal, local.Type())
+	assertForwardEcho(t, dialer.Direct(), local.Addr().String())
+	assert.Eventually(t, func() bool { return local.BytesSent() == 5 && local.BytesReceived() == 5 }, time.Second, 10*time.Millisecond)
+
+	remote, err := s.RemoteForward("127.0.0.1:0", echo)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, 0, remote.Addr().(*net.TCPAddr).Port)
+	assertForwardEcho(t, dialer.Direct(), remote.Addr().String())
+	assert.Eventually(t, func() bool { return remote.BytesSent() == 5 && remote.BytesReceived() == 5 }, time.Second, 10*time.Millisecond)
+
+	dynamic, err := s.DynamicForward("127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertForwardEcho(t, dialer.SOCKS5(dynamic.Addr().String(), "", "", nil), echo)
+	assert.Eventually(t, func() bool { return dynamic.BytesSent() == 5 && dynamic.BytesReceived() == 5 }, time.Second, 10*time.Millisecond)
+
+	// 单独关闭的转发从列表中移除
+	assert.Len(t, s.Forwards(), 3)
+	assert.NoError(t, local.Close())
+	assert.Len(t, s.Forwards(), 2)
+	_, err = net.DialTimeout("tcp", local.Addr().String(), time.Second)
+	assert.Error(t, err)
+
+	// 关闭 shell 时关闭所有转发
+	assert.NoError(t, s.Close())
+	assert.Len(t, s.Forwards(), 0)
+	_, err = net.DialTimeout("tcp", dynamic.Addr().String(), time.Second)
+	assert.Error(t, err)
+	assert.Eventually(t, func() bool {
+		c, err := net.DialTimeout("tcp", remote.Addr().String(), time.Second)
+		if err == nil {
+			_ = c.Close()
+		}
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 关闭之后不能再创建转发
+	_, err = s.LocalForward("127.0.0.1:0", echo)
+	if e, ok := err.(*core.Error); assert.True(t, ok) {
+		assert.Equal(t, "forward", e.Op)
+	}
+}
+
+func TestSshForward_CloseActiveConn(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	echo := testEchoServer(t)
+	client, err := NewSshClient(&SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+
+	f, err := NewSshLocalForward(client, "127.0.0.1:0", echo)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn, err := net.Dial("tcp", f.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("hello"))
+	_, err = io.ReadFull(conn, make([]byte, 5))
+	assert.NoError(t, err)
+
+	// 关闭转发时断开正在转发的连接
+	assert.NoError(t, f.Close())
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,49 @@
+package easyshell
+
+import (
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"time"
+)
+
+// keepAlive 定时向服务端发送 keepalive@openssh.com 请求，连续 countMax 次未收到回复时调用 onDead，直到 stop 被关闭
+//
+//	服务端对该请求的回复可能是失败（不支持该请求），但只要有回复，就说明连接正常
+func keepAlive(client *ssh.Client, interval time.Duration, countMax int, stop <-chan struct{}, onDead func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// 等待回复的请求，在收到回复之前不发送新的请求
+	var pending chan error
+	var missed int
+	for {
+		select {
+		case <-stop:
+			return
+
+		case err := <-pending:
+			pending = nil
+			if err == nil {
+				missed = 0
+			} else if missed++; missed >= countMax {
+				onDead(fmt.Errorf("keepalive to %s failed: %v", client.RemoteAddr(), err))
+				return
+			}
+
+		case <-ticker.C:
+			if pending != nil {
+				missed++
+			} else {
+				pending = make(chan error, 1)
+				go func(ch chan<- error) {
+					_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+					ch <- err
+				}(pending)
+			}
+			if missed >= countMax {
+				onDead(fmt.Errorf("no keepalive reply from %s after %d attempts", client.RemoteAddr(), missed))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package easyshell
+
+import (
+	"github.com/3th1nk/easyshell/core"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSshShell_KeepAlive(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	s, err := NewSshShell(&SshShellConfig{
+		Credential:        &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+		KeepAliveInterval: 100 * time.Millisecond,
+		KeepAliveCountMax: 2,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	// 连接正常时，心跳不影响读写
+	var out []string
+	assert.NoError(t, s.Write("echo hello"))
+	assert.NoError(t, s.ReadToEndLine(5*time.Second, func(lines []string) {
+		out = append(out, lines...)
+	}))
+	assert.Equal(t, []string{"hello"}, out)
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(t, s.Disconnected())
+
+	// 连接假死时，正在进行的读操作立即返回
+	assert.NoError(t, s.Write("hang"))
+	atomic.StoreInt32(&server.dropGlobalReqs, 1)
+	start := time.Now()
+	err = s.ReadToEndLine(time.Minute, nil)
+	assert.True(t, core.IsDisconnected(err), err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 之后的读写操作也立即返回
+	assert.True(t, core.IsDisconnected(s.Write("echo hello")))
+	assert.True(t, core.IsDisconnected(s.ReadToEndLine(time.Minute, nil)))
+}
@@ -11,9 +11,13 @@ import (
 // SftpClient 获取sftp客户端，调用方无需Close
 func (this *SshShell) SftpClient(opt ...sftp.ClientOption) (*sftp.Client, error) {
 	if this.sftp == nil {
+		client := this.Client()
+		if client == nil {
+			return nil, &core.Error{Op: "sftp", Err: fmt.Errorf("client closed")}
+		}
 		var err error
-		if this.sftp, err = sftp.NewClient(this.client, opt...); err != nil {
-			return nil, &core.Error{Op: "sftp", Addr: this.client.RemoteAddr().String(), Err: err}
+		if this.sftp, err = sftp.NewClient(client, opt...); err != nil {
+			return nil, &core.Error{Op: "sftp", Addr: client.RemoteAddr().String(), Err: err}
 		}
 	}
 	return this.sftp, nil
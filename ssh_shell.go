@@ -1,12 +1,16 @@
 package easyshell
 
 import (
+	"context"
+	"fmt"
 	"github.com/3th1nk/easygo/util"
 	"github.com/3th1nk/easyshell/core"
 	"github.com/3th1nk/easyshell/internal/misc"
 	"github.com/3th1nk/easyshell/pkg/interceptor"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"io"
+	"sync"
 	"time"
 )
 
@@ -17,6 +21,11 @@ type SshShellConfig struct {
 	Term       string         // 模拟终端类型，默认值 VT100
 	TermHeight int            // 模拟终端高度，默认值 200
 	TermWidth  int            // 模拟终端宽度，默认值 256，宽度太小可能会出现乱码（多字节编码被回车换行截断）
+
+	// 心跳间隔，大于 0 时定时发送 keepalive@openssh.com 请求检测连接是否可用，默认不发送
+	KeepAliveInterval time.Duration
+	// 心跳连续未回复的最大次数，超过后认为连接已断开：正在进行以及之后的读写操作会立即返回 Op 为 disconnected 的错误，默认值 3
+	KeepAliveCountMax int
 }
 
 func (c *SshShellConfig) EnsureInit() {
@@ -29,6 +38,9 @@ func (c *SshShellConfig) EnsureInit() {
 	if c.TermWidth <= 0 {
 		c.TermWidth = 256
 	}
+	if c.KeepAliveCountMax <= 0 {
+		c.KeepAliveCountMax = 3
+	}
 }
 
 func NewSshShell(config ...*SshShellConfig) (*SshShell, error) {
@@ -65,10 +77,34 @@ func NewSshShellFromClient(client *ssh.Client, config ...*SshShellConfig) (*SshS
 	}
 	cfg.EnsureInit()
 
+	session, pIn, pOut, pErr, err := newSshShellSession(client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	shell := &SshShell{
+		ReadWriter: core.New(pIn, pOut, pErr, cfg.Config),
+		cfg:        cfg,
+		client:     client,
+		session:    session,
+	}
+	shell.setWriteInterrupter()
+	shell.headLine = shell.readHeadLine()
+	shell.startKeepAlive()
+	if err = shell.RunInitCommands(); err != nil {
+		_ = shell.Close()
+		return nil, &core.Error{Op: "init", Addr: client.RemoteAddr().String(), Err: err}
+	}
+	shell.SetConnector(shell.reconnect)
+	return shell, nil
+}
+
+// newSshShellSession 在 client 上创建一个会话，并启动交互式 shell
+func newSshShellSession(client *ssh.Client, cfg *SshShellConfig) (session *ssh.Session, in io.Writer, out, errOut io.Reader, err error) {
 	addr := client.RemoteAddr().String()
-	session, err := client.NewSession()
+	session, err = client.NewSession()
 	if err != nil {
-		return nil, &core.Error{Op: "session", Addr: addr, Err: err}
+		return nil, nil, nil, nil, &core.Error{Op: "session", Addr: addr, Err: err}
 	}
 
 	echo := util.IfInt(cfg.Echo, 1, 0)
@@ -78,40 +114,143 @@ func NewSshShellFromClient(client *ssh.Client, config ...*SshShellConfig) (*SshS
 		ssh.TTY_OP_OSPEED: 14400,
 	}); err != nil {
 		_ = session.Close()
-		return nil, &core.Error{Op: "term", Addr: addr, Err: err}
+		return nil, nil, nil, nil, &core.Error{Op: "term", Addr: addr, Err: err}
 	}
 
-	pIn, _ := session.StdinPipe()
-	pOut, _ := session.StdoutPipe()
-	pErr, _ := session.StderrPipe()
+	in, _ = session.StdinPipe()
+	out, _ = session.StdoutPipe()
+	errOut, _ = session.StderrPipe()
 
 	if err = session.Shell(); err != nil {
 		_ = session.Close()
-		return nil, &core.Error{Op: "shell", Addr: addr, Err: err}
+		return nil, nil, nil, nil, &core.Error{Op: "shell", Addr: addr, Err: err}
 	}
-	r := core.New(pIn, pOut, pErr, cfg.Config)
+	return session, in, out, errOut, nil
+}
 
+type SshShell struct {
+	*core.ReadWriter
+	cfg           *SshShellConfig
+	clientMu      sync.Mutex // 保护 client：自动重连时会替换 client，端口转发需要绑定到当前的 client
+	client        *ssh.Client
+	session       *ssh.Session
+	sftp          *sftp.Client
+	ownClient     bool
+	headLine      []string
+	stopKeepAlive chan struct{}
+	keepAliveDone chan struct{}
+	forwardMu     sync.Mutex
+	forwards      []*SshForward
+}
+
+// readHeadLine 读取登录后的输出
+func (this *SshShell) readHeadLine() []string {
 	// 此时可能会有一些输出，可能是欢迎信息、日志打印、密码修改提示等，需要读取并处理，防止影响后续操作
 	//	对于密码修改提示，部分设备是会提示密码过期，是否修改密码，也有设备是直接提示输入密码，这里只处理前者，总是答复否，不自动修改密码
 	var headLine []string
-	_ = r.ReadToEndLine(5*time.Second, func(lines []string) {
+	_ = this.ReadToEndLine(5*time.Second, func(lines []string) {
 		headLine = append(headLine, lines...)
 	}, interceptor.AlwaysNo(true))
-	headLine = misc.TrimEmptyLine(headLine)
+	return misc.TrimEmptyLine(headLine)
+}
 
-	return &SshShell{ReadWriter: r, client: client, session: session, headLine: headLine}, nil
+func (this *SshShell) startKeepAlive() {
+	if this.cfg.KeepAliveInterval <= 0 {
+		return
+	}
+	client, session, r := this.Client(), this.session, this.ReadWriter
+	addr := client.RemoteAddr().String()
+	stop, done := make(chan struct{}), make(chan struct{})
+	this.stopKeepAlive, this.keepAliveDone = stop, done
+	go func() {
+		defer close(done)
+		keepAlive(client, this.cfg.KeepAliveInterval, this.cfg.KeepAliveCountMax, stop, func(err error) {
+			r.Disconnect(&core.Error{Op: "disconnected", Addr: addr, Err: err})
+			// 关闭会话，释放阻塞在会话上的读写
+			_ = session.Close()
+		})
+	}()
 }
 
-type SshShell struct {
-	*core.ReadWriter
-	client    *ssh.Client
-	session   *ssh.Session
-	sftp      *sftp.Client
-	ownClient bool
-	headLine  []string
+// setWriteInterrupter ssh 会话的输入流无法设置写超时，写操作被取消时只能关闭会话，之后的读写操作会返回 Op 为 disconnected 的错误（启用自动重连时会重新连接）
+func (this *SshShell) setWriteInterrupter() {
+	session, r := this.session, this.ReadWriter
+	addr := this.Client().RemoteAddr().String()
+	r.SetWriteInterrupter(func() {
+		r.Disconnect(&core.Error{Op: "disconnected", Addr: addr, Err: fmt.Errorf("write interrupted")})
+		_ = session.Close()
+	})
+}
+
+// stopKeepAliveAndWait 停止心跳，并等待心跳协程退出
+func (this *SshShell) stopKeepAliveAndWait() {
+	if this.stopKeepAlive != nil {
+		close(this.stopKeepAlive)
+		<-this.keepAliveDone
+		this.stopKeepAlive, this.keepAliveDone = nil, nil
+	}
+}
+
+// reconnect 自动重连：关闭当前会话（如果是自己创建的 Client，同时关闭并重新创建 Client），然后重新创建会话
+func (this *SshShell) reconnect(ctx context.Context, r *core.ReadWriter) error {
+	this.stopKeepAliveAndWait()
+	if this.sftp != nil {
+		_ = this.sftp.Close()
+		this.sftp = nil
+	}
+	if this.session != nil {
+		_ = this.session.Close()
+		this.session = nil
+	}
+
+	if this.ownClient {
+		if err := this.renewClient(ctx); err != nil {
+			return err
+		}
+	}
+	client := this.Client()
+	if client == nil {
+		return &core.Error{Op: "session", Err: fmt.Errorf("client closed")}
+	}
+
+	session, pIn, pOut, pErr, err := newSshShellSession(client, this.cfg)
+	if err != nil {
+		return err
+	}
+	this.session = session
+	r.Reset(pIn, pOut, pErr)
+	this.setWriteInterrupter()
+	this.headLine = this.readHeadLine()
+	this.startKeepAlive()
+	return nil
+}
+
+// renewClient 关闭并重新创建 Client，重新创建 Client 后，原 Client 上的端口转发均已失效；ctx 结束时停止等待连接建立
+func (this *SshShell) renewClient(ctx context.Context) error {
+	this.clientMu.Lock()
+	defer this.clientMu.Unlock()
+	this.closeForwards()
+	if this.client != nil {
+		_ = this.client.Close()
+		this.client = nil
+	}
+	client, err := dialContext(ctx, func() (io.Closer, error) {
+		client, err := NewSshClient(this.cfg.Credential)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	})
+	if err != nil {
+		return err
+	}
+	this.client = client.(*ssh.Client)
+	return nil
 }
 
 func (this *SshShell) Client() *ssh.Client {
+	this.clientMu.Lock()
+	defer this.clientMu.Unlock()
 	return this.client
 }
 
@@ -123,7 +262,77 @@ func (this *SshShell) HeadLine() []string {
 	return this.headLine
 }
 
+// LocalForward 本地转发，参考 NewSshLocalForward，转发随 Close 一同关闭
+func (this *SshShell) LocalForward(localAddr, remoteAddr string) (*SshForward, error) {
+	return this.newForward(localAddr, func(client *ssh.Client) (*SshForward, error) {
+		return NewSshLocalForward(client, localAddr, remoteAddr)
+	})
+}
+
+// RemoteForward 远程转发，参考 NewSshRemoteForward，转发随 Close 一同关闭
+func (this *SshShell) RemoteForward(remoteAddr, localAddr string) (*SshForward, error) {
+	return this.newForward(remoteAddr, func(client *ssh.Client) (*SshForward, error) {
+		return NewSshRemoteForward(client, remoteAddr, localAddr)
+	})
+}
+
+// DynamicForward 动态转发(SOCKS5)，参考 NewSshDynamicForward，转发随 Close 一同关闭
+func (this *SshShell) DynamicForward(localAddr string) (*SshForward, error) {
+	return this.newForward(localAddr, func(client *ssh.Client) (*SshForward, error) {
+		return NewSshDynamicForward(client, localAddr)
+	})
+}
+
+// Forwards 返回尚未关闭的端口转发
+func (this *SshShell) Forwards() []*SshForward {
+	this.forwardMu.Lock()
+	defer this.forwardMu.Unlock()
+	return append([]*SshForward(nil), this.forwards...)
+}
+
+// newForward 使用当前的 Client 创建端口转发，创建过程中持有 clientMu，避免与自动重连同时进行时绑定到已关闭的 Client
+func (this *SshShell) newForward(addr string, create func(client *ssh.Client) (*SshForward, error)) (*SshForward, error) {
+	this.clientMu.Lock()
+	defer this.clientMu.Unlock()
+	if this.client == nil {
+		return nil, &core.Error{Op: "forward", Addr: addr, Err: fmt.Errorf("client closed")}
+	}
+	f, err := create(this.client)
+	if err != nil {
+		return nil, err
+	}
+	this.addForward(f)
+	return f, nil
+}
+
+func (this *SshShell) addForward(f *SshForward) {
+	this.forwardMu.Lock()
+	defer this.forwardMu.Unlock()
+	if f.setOnClose(this.removeForward) {
+		this.forwards = append(this.forwards, f)
+	}
+}
+
+func (this *SshShell) removeForward(f *SshForward) {
+	this.forwardMu.Lock()
+	defer this.forwardMu.Unlock()
+	for i, v := range this.forwards {
+		if v == f {
+			this.forwards = append(this.forwards[:i], this.forwards[i+1:]...)
+			break
+		}
+	}
+}
+
+func (this *SshShell) closeForwards() {
+	for _, f := range this.Forwards() {
+		_ = f.Close()
+	}
+}
+
 func (this *SshShell) Close() (err error) {
+	this.stopKeepAliveAndWait()
+
 	if this.sftp != nil {
 		if e := this.sftp.Close(); e != nil {
 			err = e
@@ -138,6 +347,8 @@ func (this *SshShell) Close() (err error) {
 		this.session = nil
 	}
 
+	this.clientMu.Lock()
+	this.closeForwards()
 	if this.client != nil {
 		if this.ownClient {
 			if e := this.client.Close(); e != nil && err == nil {
@@ -146,6 +357,7 @@ func (this *SshShell) Close() (err error) {
 		}
 		this.client = nil
 	}
+	this.clientMu.Unlock()
 	this.ReadWriter.Stop()
 	return
 }
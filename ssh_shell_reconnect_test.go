@@ -0,0 +1,117 @@
+package easyshell
+
+import (
+	"bytes"
+	"context"
+	"github.com/3th1nk/easyshell/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSshShell_Reconnect(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	s, err := NewSshShell(&SshShellConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+		Config: core.Config{
+			InitCommands: []string{"terminal length 0"},
+			Reconnect:    core.ReconnectPolicy{MaxAttempts: 3, Backoff: 50 * time.Millisecond},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	assert.Equal(t, []string{"terminal length 0"}, server.Commands())
+
+	run := func(cmd string) ([]string, error) {
+		var out []string
+		if err := s.Write(cmd); err != nil {
+			return nil, err
+		}
+		err := s.ReadToEndLine(5*time.Second, func(lines []string) {
+			out = append(out, lines...)
+		})
+		return out, err
+	}
+
+	out, err := run("echo 1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, out)
+
+	// 命令执行过程中连接断开：返回不可重试的错误，并自动重连、重新执行初始化命令
+	assert.NoError(t, s.Write("hang"))
+	assert.Eventually(t, func() bool { return len(server.Commands()) == 3 }, time.Second, 10*time.Millisecond)
+	server.DropConns()
+	err = s.ReadToEndLine(5*time.Second, nil)
+	if v, _ := err.(*core.ReconnectError); assert.NotNil(t, v, err) {
+		assert.False(t, v.Retryable)
+		assert.True(t, v.Reconnected)
+	}
+	assert.False(t, core.IsRetryable(err))
+	assert.Equal(t, []string{"terminal length 0", "echo 1", "hang", "terminal length 0"}, server.Commands())
+
+	out, err = run("echo 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, out)
+}
+
+func TestSshShell_ReconnectFailed(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	s, err := NewSshShell(&SshShellConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123", Timeout: time.Second},
+		Config: core.Config{
+			Reconnect: core.ReconnectPolicy{MaxAttempts: 2, Backoff: 10 * time.Millisecond},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	assert.NoError(t, s.Write("hang"))
+	server.Close()
+	err = s.ReadToEndLine(5*time.Second, nil)
+	if v, _ := err.(*core.ReconnectError); assert.NotNil(t, v, err) {
+		assert.False(t, v.Retryable)
+		assert.False(t, v.Reconnected)
+	}
+
+	// 重连失败后，写入命令前会再次尝试重连，失败时返回可以重试的错误
+	err = s.Write("echo 1")
+	if v, _ := err.(*core.ReconnectError); assert.NotNil(t, v, err) {
+		assert.True(t, v.Retryable)
+		assert.False(t, v.Reconnected)
+	}
+	assert.True(t, core.IsRetryable(err))
+}
+
+func TestSshShell_WriteContext(t *testing.T) {
+	server := newTestSshServer(t, "test", "test@123")
+	s, err := NewSshShell(&SshShellConfig{
+		Credential: &SshCredential{Host: server.Host(), Port: server.Port(), User: "test", Password: "test@123"},
+		Config: core.Config{
+			Reconnect: core.ReconnectPolicy{MaxAttempts: 3, Backoff: 50 * time.Millisecond},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	// 服务端不再读取输入，写入超过窗口大小的内容时阻塞
+	assert.NoError(t, s.Write("block"))
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = s.WriteRawContext(ctx, bytes.Repeat([]byte("a"), 8<<20))
+	assert.True(t, core.IsTimeout(err), err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.True(t, core.IsDisconnected(s.Disconnected()))
+
+	// 会话已关闭，写入命令前自动重连
+	assert.NoError(t, s.WriteContext(context.Background(), "echo 1"))
+	var out []string
+	assert.NoError(t, s.ReadToEndLineContext(context.Background(), func(lines []string) { out = append(out, lines...) }))
+	assert.Equal(t, []string{"1"}, out)
+}
@@ -1,9 +1,12 @@
 package easyshell
 
 import (
+	"context"
+	"fmt"
 	"github.com/3th1nk/easyshell/core"
 	"github.com/3th1nk/easyshell/internal/misc"
 	"github.com/3th1nk/easyshell/pkg/telnet"
+	"io"
 	"strings"
 	"time"
 )
@@ -50,26 +53,61 @@ func NewTelnetShellFromClient(client *telnet.Client, config ...*TelnetShellConfi
 	}
 	cfg.EnsureInit()
 
-	r := core.New(client, client, nil, cfg.Config)
-	// 读取提示符
-	_ = r.Write("")
-	_ = r.ReadToEndLine(3*time.Second, func(lines []string) {})
-
-	headLine := misc.TrimEmptyLine(strings.Split(client.Welcome(), "\n"))
-	return &TelnetShell{
-		ReadWriter: r,
+	shell := &TelnetShell{
+		ReadWriter: core.New(client, client, nil, cfg.Config),
+		cfg:        cfg,
 		client:     client,
-		headLine:   headLine,
-	}, nil
+	}
+	shell.readPrompt()
+	if err := shell.RunInitCommands(); err != nil {
+		shell.ReadWriter.Stop()
+		return nil, &core.Error{Op: "init", Addr: client.RemoteAddr().String(), Err: err}
+	}
+	shell.SetConnector(shell.reconnect)
+	return shell, nil
 }
 
 type TelnetShell struct {
 	*core.ReadWriter
+	cfg       *TelnetShellConfig
 	client    *telnet.Client
 	ownClient bool
 	headLine  []string
 }
 
+func (this *TelnetShell) readPrompt() {
+	// 读取提示符
+	_ = this.Write("")
+	_ = this.ReadToEndLine(3*time.Second, func(lines []string) {})
+	this.headLine = misc.TrimEmptyLine(strings.Split(this.client.Welcome(), "\n"))
+}
+
+// reconnect 自动重连，仅当 Client 是自己创建的时候才能重连
+func (this *TelnetShell) reconnect(ctx context.Context, r *core.ReadWriter) error {
+	if !this.ownClient || this.cfg.Credential == nil {
+		return &core.Error{Op: "dial", Err: fmt.Errorf("can not reconnect with an external client")}
+	}
+	if this.client != nil {
+		_ = this.client.Close()
+		this.client = nil
+	}
+	c, err := dialContext(ctx, func() (io.Closer, error) {
+		client, err := NewTelnetClient(this.cfg.Credential)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	})
+	if err != nil {
+		return err
+	}
+	client := c.(*telnet.Client)
+	this.client = client
+	r.Reset(client, client, nil)
+	this.readPrompt()
+	return nil
+}
+
 func (this *TelnetShell) Client() *telnet.Client {
 	return this.client
 }